@@ -0,0 +1,68 @@
+package httpd
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+var redactedHeaders = map[string]struct{}{
+	HeaderAuthorization:   {},
+	"Proxy-Authorization": {},
+	"Cookie":              {},
+	HeaderAuthSignature:   {},
+}
+
+type accessLogLevel int
+
+const (
+	accessLogSkip accessLogLevel = iota
+	accessLogInfo
+	accessLogWarn
+)
+
+func accessLogLevelOf(cfg *Cfg, r *http.Request, statusCode int, cost time.Duration) accessLogLevel {
+	if cfg.SlowRequestThreshold > 0 && cost >= cfg.SlowRequestThreshold {
+		return accessLogWarn
+	}
+	if statusCode >= http.StatusBadRequest {
+		return accessLogInfo
+	}
+	if accessLogExcluded(cfg.AccessLogExcludePaths, r.URL.Path) {
+		return accessLogSkip
+	}
+	if statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices &&
+		cfg.AccessLogSampleRatio < 1 && rand.Float64() >= cfg.AccessLogSampleRatio {
+		return accessLogSkip
+	}
+	return accessLogInfo
+}
+
+// accessLogExcluded reports whether p matches any of patterns, a pattern ending with / matches all paths under it,
+// others are matched by path.Match, in which * does not match /.
+func accessLogExcluded(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(p, pattern) {
+			return true
+		}
+		matched, _ := path.Match(pattern, p)
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	m := make(map[string]string, len(header))
+	for k, v := range header {
+		if _, redact := redactedHeaders[k]; redact {
+			m[k] = "<redacted>"
+			continue
+		}
+		m[k] = strings.Join(v, ", ")
+	}
+	return m
+}
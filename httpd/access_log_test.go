@@ -0,0 +1,77 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogExcluded(t *testing.T) {
+	patterns := []string{"/metrics", "/debug/", "/static/*.js"}
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{path: "/metrics", excluded: true},
+		{path: "/metrics/x"},
+		{path: "/debug/", excluded: true},
+		{path: "/debug/pprof/profile", excluded: true},
+		{path: "/debug"},
+		{path: "/debugger"},
+		{path: "/static/app.js", excluded: true},
+		{path: "/static/js/app.js"},
+		{path: "/api"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.excluded, accessLogExcluded(patterns, tt.path), tt.path)
+	}
+}
+
+func TestAccessLogLevelOf(t *testing.T) {
+	cfg := NewCfg()
+	cfg.AccessLogExcludePaths = []string{"/metrics"}
+	cfg.AccessLogSampleRatio = 0
+	cfg.SlowRequestThreshold = time.Second
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		cost   time.Duration
+		want   accessLogLevel
+	}{
+		{name: "sampled out", path: "/api", status: http.StatusOK, want: accessLogSkip},
+		{name: "excluded", path: "/metrics", status: http.StatusOK, want: accessLogSkip},
+		{name: "redirect not sampled", path: "/api", status: http.StatusFound, want: accessLogInfo},
+		{name: "error always logged", path: "/api", status: http.StatusInternalServerError, want: accessLogInfo},
+		{name: "excluded error logged", path: "/metrics", status: http.StatusNotFound, want: accessLogInfo},
+		{name: "slow", path: "/metrics", status: http.StatusOK, cost: time.Second, want: accessLogWarn},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		require.Equal(t, tt.want, accessLogLevelOf(cfg, r, tt.status, tt.cost), tt.name)
+	}
+
+	// all logged by default
+	cfg = NewCfg()
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, accessLogInfo, accessLogLevelOf(cfg, r, http.StatusOK, time.Hour))
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderAuthorization, "Bearer token")
+	header.Set("Cookie", "session=1")
+	header.Add("Accept", "text/html")
+	header.Add("Accept", "application/json")
+	require.Equal(t, map[string]string{
+		HeaderAuthorization: "<redacted>",
+		"Cookie":            "<redacted>",
+		"Accept":            "text/html, application/json",
+	}, redactHeaders(header))
+}
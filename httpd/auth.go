@@ -0,0 +1,310 @@
+package httpd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+const (
+	HeaderAuthorization   = "Authorization"
+	HeaderAuthKey         = "X-Auth-Key"
+	HeaderAuthTimestamp   = "X-Auth-Timestamp"
+	HeaderAuthSignature   = "X-Auth-Signature"
+	headerWWWAuthenticate = "WWW-Authenticate"
+
+	authRealm = "httpd"
+)
+
+type authenticator func(r *http.Request) (string, bool)
+
+type auth struct {
+	authenticators []authenticator
+	challenge      string
+}
+
+func newAuth(cfg *Cfg) (*auth, error) {
+	a := &auth{}
+
+	if cfg.AuthBasicUsersFile != "" {
+		users, err := loadKVFile(cfg.AuthBasicUsersFile)
+		if err != nil {
+			return nil, errs.Wrap(err, "load basic auth users file fail")
+		}
+		a.authenticators = append(a.authenticators, basicAuthenticator(users))
+		a.challenge = `Basic realm="` + authRealm + `"`
+	}
+
+	if cfg.AuthBearerTokensFile != "" {
+		lines, err := loadLines(cfg.AuthBearerTokensFile)
+		if err != nil {
+			return nil, errs.Wrap(err, "load bearer tokens file fail")
+		}
+		a.authenticators = append(a.authenticators, bearerAuthenticator(lines))
+		if a.challenge == "" {
+			a.challenge = `Bearer realm="` + authRealm + `"`
+		}
+	}
+
+	if cfg.AuthHMACKeysFile != "" {
+		keys, err := loadKVFile(cfg.AuthHMACKeysFile)
+		if err != nil {
+			return nil, errs.Wrap(err, "load hmac keys file fail")
+		}
+		secrets := make(map[string][]byte, len(keys))
+		for id, secret := range keys {
+			secrets[id] = []byte(secret)
+		}
+		a.authenticators = append(a.authenticators, hmacAuthenticator(secrets, cfg.AuthHMACMaxSkew, cfg.AuthHMACMaxBodyBytes))
+	}
+
+	return a, nil
+}
+
+func (a *auth) enabled() bool {
+	return a != nil && len(a.authenticators) > 0
+}
+
+func (a *auth) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := a.authenticate(r)
+		if !ok {
+			a.reject(w)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withAuthIdentity(r.Context(), id)))
+	})
+}
+
+func (a *auth) authenticate(r *http.Request) (string, bool) {
+	for _, authenticate := range a.authenticators {
+		id, ok := authenticate(r)
+		if ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func (a *auth) reject(w http.ResponseWriter) {
+	if a.challenge != "" {
+		w.Header().Set(headerWWWAuthenticate, a.challenge)
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// Auth returns a middleware which authenticates requests by the auth options in cfg of this server,
+// request is accepted if any of the configured methods succeed, all requests pass through when no auth configured.
+// If RateLimitKey is auth, requests are rate limited by the authenticated identity, or by ip if authentication failed.
+func (s *Server) Auth() MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			a := s.st.Load().auth
+			if !a.enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, ok := a.authenticate(r)
+			if !allowPending(w, r, id) {
+				return
+			}
+			if !ok {
+				a.reject(w)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withAuthIdentity(r.Context(), id)))
+		})
+	}
+}
+
+// BasicAuth returns a middleware which authenticates requests by http basic auth, users is username to password.
+func BasicAuth(users map[string]string) MiddlewareFunc {
+	a := &auth{
+		authenticators: []authenticator{basicAuthenticator(users)},
+		challenge:      `Basic realm="` + authRealm + `"`,
+	}
+	return a.middleware
+}
+
+// BearerAuth returns a middleware which authenticates requests by static bearer tokens.
+func BearerAuth(tokens []string) MiddlewareFunc {
+	a := &auth{
+		authenticators: []authenticator{bearerAuthenticator(tokens)},
+		challenge:      `Bearer realm="` + authRealm + `"`,
+	}
+	return a.middleware
+}
+
+// HMACAuth returns a middleware which authenticates requests signed by HMAC-SHA256, keys is key id to secret.
+// Client should set X-Auth-Key to key id, X-Auth-Timestamp to unix seconds and X-Auth-Signature to
+// hex(hmac_sha256(secret, method + "\n" + request uri + "\n" + timestamp + "\n" + hex(sha256(body)))).
+// Body is read into memory to verify the signature, requests with body larger than maxBodyBytes are rejected,
+// zero means no limit.
+func HMACAuth(keys map[string][]byte, maxSkew time.Duration, maxBodyBytes int64) MiddlewareFunc {
+	a := &auth{
+		authenticators: []authenticator{hmacAuthenticator(keys, maxSkew, maxBodyBytes)},
+	}
+	return a.middleware
+}
+
+func basicAuthenticator(users map[string]string) authenticator {
+	hashed := make(map[string][32]byte, len(users))
+	for user, password := range users {
+		hashed[user] = sha256.Sum256([]byte(password))
+	}
+
+	return func(r *http.Request) (string, bool) {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return "", false
+		}
+		expected, exists := hashed[user]
+		actual := sha256.Sum256([]byte(password))
+		if subtle.ConstantTimeCompare(expected[:], actual[:]) != 1 || !exists {
+			return "", false
+		}
+		return "basic:" + user, true
+	}
+}
+
+func bearerAuthenticator(tokens []string) authenticator {
+	hashed := make(map[[32]byte]struct{}, len(tokens))
+	for _, token := range tokens {
+		hashed[sha256.Sum256([]byte(token))] = struct{}{}
+	}
+
+	return func(r *http.Request) (string, bool) {
+		token, found := strings.CutPrefix(r.Header.Get(HeaderAuthorization), "Bearer ")
+		if !found || token == "" {
+			return "", false
+		}
+		sum := sha256.Sum256([]byte(token))
+		if _, exists := hashed[sum]; !exists {
+			return "", false
+		}
+		return "bearer:" + hex.EncodeToString(sum[:4]), true
+	}
+}
+
+func hmacAuthenticator(keys map[string][]byte, maxSkew time.Duration, maxBodyBytes int64) authenticator {
+	return func(r *http.Request) (string, bool) {
+		keyID := r.Header.Get(HeaderAuthKey)
+		ts := r.Header.Get(HeaderAuthTimestamp)
+		sig, err := hex.DecodeString(r.Header.Get(HeaderAuthSignature))
+		if keyID == "" || ts == "" || err != nil {
+			return "", false
+		}
+
+		secret, exists := keys[keyID]
+		if !exists {
+			return "", false
+		}
+
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return "", false
+		}
+		skew := time.Since(time.Unix(sec, 0))
+		if maxSkew > 0 && (skew > maxSkew || skew < -maxSkew) {
+			return "", false
+		}
+
+		bodySum, err := sumBody(r, maxBodyBytes)
+		if err != nil {
+			return "", false
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(r.Method + "\n" + r.RequestURI + "\n" + ts + "\n" + bodySum))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return "", false
+		}
+		return "hmac:" + keyID, true
+	}
+}
+
+var errBodyTooLarge = errors.New("body too large")
+
+// sumBody returns hex sha256 of request body, and restore the body for next handlers.
+// It fails without reading the rest if body is larger than maxBytes, zero means no limit.
+func sumBody(r *http.Request, maxBytes int64) (string, error) {
+	h := sha256.New()
+	if r.Body == nil || r.Body == http.NoBody {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	if maxBytes > 0 && r.ContentLength > maxBytes {
+		return "", errBodyTooLarge
+	}
+
+	var reader io.Reader = r.Body
+	if maxBytes > 0 {
+		reader = io.LimitReader(r.Body, maxBytes+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		_ = r.Body.Close()
+		return "", err
+	}
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		_ = r.Body.Close()
+		return "", errBodyTooLarge
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func loadLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errs.Wrapf(err, "open file fail: %s", path)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, errs.Wrapf(err, "read file fail: %s", path)
+	}
+	return lines, nil
+}
+
+// loadKVFile loads file with key:value per line.
+func loadKVFile(path string) (map[string]string, error) {
+	lines, err := loadLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	kv := make(map[string]string, len(lines))
+	for i, line := range lines {
+		k, v, found := strings.Cut(line, ":")
+		if !found || k == "" {
+			return nil, errs.Errorf("invalid line %d in file %s, expect key:value", i+1, path)
+		}
+		kv[k] = v
+	}
+	return kv, nil
+}
@@ -0,0 +1,186 @@
+package httpd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// identityHandler responds the authenticated identity and the body it read.
+var identityHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	_, _ = w.Write([]byte(AuthIdentity(r.Context()) + " " + string(body)))
+})
+
+func TestBasicAuth(t *testing.T) {
+	mw := BasicAuth(map[string]string{"alice": "secret"})
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		status   int
+	}{
+		{name: "ok", user: "alice", password: "secret", status: http.StatusOK},
+		{name: "wrong password", user: "alice", password: "wrong", status: http.StatusUnauthorized},
+		{name: "unknown user", user: "bob", password: "secret", status: http.StatusUnauthorized},
+		{name: "no credentials", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			w := httptest.NewRecorder()
+			mw(identityHandler).ServeHTTP(w, r)
+			require.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				require.Equal(t, "basic:alice ", w.Body.String())
+			} else {
+				require.Contains(t, w.Header().Get(headerWWWAuthenticate), "Basic")
+			}
+		})
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	mw := BearerAuth([]string{"token"})
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "ok", header: "Bearer token", status: http.StatusOK},
+		{name: "wrong token", header: "Bearer other", status: http.StatusUnauthorized},
+		{name: "empty token", header: "Bearer ", status: http.StatusUnauthorized},
+		{name: "wrong scheme", header: "Basic token", status: http.StatusUnauthorized},
+		{name: "no header", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(HeaderAuthorization, tt.header)
+			}
+			w := httptest.NewRecorder()
+			mw(identityHandler).ServeHTTP(w, r)
+			require.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				require.True(t, strings.HasPrefix(w.Body.String(), "bearer:"))
+			}
+		})
+	}
+}
+
+func signHMAC(secret []byte, method string, uri string, ts string, body string) string {
+	bodySum := sha256.Sum256([]byte(body))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + uri + "\n" + ts + "\n" + hex.EncodeToString(bodySum[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACAuth(t *testing.T) {
+	secret := []byte("secret")
+	mw := HMACAuth(map[string][]byte{"k1": secret}, time.Minute, 16)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name     string
+		keyID    string
+		ts       string
+		body     string
+		signBody string
+		secret   []byte
+		status   int
+	}{
+		{name: "ok", keyID: "k1", ts: now, body: "hello", status: http.StatusOK},
+		{name: "ok without body", keyID: "k1", ts: now, status: http.StatusOK},
+		{name: "wrong secret", keyID: "k1", ts: now, body: "hello", secret: []byte("other"), status: http.StatusUnauthorized},
+		{name: "unknown key", keyID: "k2", ts: now, body: "hello", status: http.StatusUnauthorized},
+		{name: "body tampered", keyID: "k1", ts: now, body: "hello", signBody: "hellO", status: http.StatusUnauthorized},
+		{name: "replayed after max skew", keyID: "k1", ts: strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10), body: "hello", status: http.StatusUnauthorized},
+		{name: "timestamp in future", keyID: "k1", ts: strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10), body: "hello", status: http.StatusUnauthorized},
+		{name: "invalid timestamp", keyID: "k1", ts: "now", body: "hello", status: http.StatusUnauthorized},
+		{name: "body too large", keyID: "k1", ts: now, body: strings.Repeat("a", 17), status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sec, signBody := tt.secret, tt.signBody
+			if sec == nil {
+				sec = secret
+			}
+			if signBody == "" {
+				signBody = tt.body
+			}
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/api?x=1", body)
+			r.Header.Set(HeaderAuthKey, tt.keyID)
+			r.Header.Set(HeaderAuthTimestamp, tt.ts)
+			r.Header.Set(HeaderAuthSignature, signHMAC(sec, http.MethodPost, "/api?x=1", tt.ts, signBody))
+			w := httptest.NewRecorder()
+			mw(identityHandler).ServeHTTP(w, r)
+			require.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				// body is restored for next handlers
+				require.Equal(t, "hmac:k1 "+tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSumBodyLimitWithoutContentLength(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(strings.Repeat("a", 100))))
+	r.ContentLength = -1
+	_, err := sumBody(r, 10)
+	require.ErrorIs(t, err, errBodyTooLarge)
+}
+
+func TestBuiltinHandlersAuth(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokensFile, []byte("token\n"), 0o600))
+
+	cfg := NewCfg()
+	cfg.AuthBearerTokensFile = tokensFile
+	cfg.OpenAPIPath = "GET /openapi.json"
+	cfg.RoutesPath = "GET /routes"
+	base := startHttpd(t, cfg, func(*Httpd) {})
+
+	tests := []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{path: "/openapi.json", status: http.StatusUnauthorized},
+		{path: "/openapi.json", token: "token", status: http.StatusOK},
+		{path: "/routes", status: http.StatusUnauthorized},
+		{path: "/routes", token: "token", status: http.StatusOK},
+		{path: "/healthz", status: http.StatusOK},
+		{path: "/readyz", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, base+tt.path, nil)
+		require.NoError(t, err)
+		if tt.token != "" {
+			req.Header.Set(HeaderAuthorization, "Bearer "+tt.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, tt.status, resp.StatusCode, tt.path)
+	}
+}
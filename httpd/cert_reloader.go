@@ -0,0 +1,91 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/fsnotify/fsnotify"
+)
+
+const certReloadDebounce = 200 * time.Millisecond
+
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	err := cr.reload()
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return errs.Wrapf(err, "load x509 key pair fail, cert: %s, key: %s", cr.certFile, cr.keyFile)
+	}
+	cr.cert.Store(&cert)
+	return nil
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.cert.Load(), nil
+}
+
+// watch watches the directories of cert and key file rather than the files themselves,
+// because tools like cert-manager rotate certificates by swapping symlinks in the directory.
+func (cr *certReloader) watch(stopCh <-chan struct{}, onReload func(error)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errs.Wrap(err, "create fs watcher fail")
+	}
+
+	dirs := map[string]struct{}{
+		filepath.Dir(cr.certFile): {},
+		filepath.Dir(cr.keyFile):  {},
+	}
+	for dir := range dirs {
+		err = w.Add(dir)
+		if err != nil {
+			w.Close()
+			return errs.Wrapf(err, "watch dir fail: %s", dir)
+		}
+	}
+
+	go func() {
+		defer w.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-stopCh:
+				return
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				debounce = time.After(certReloadDebounce)
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			case <-debounce:
+				debounce = nil
+				onReload(cr.reload())
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package httpd
+
+import (
+	"crypto/x509"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rotateCert replaces cert and key files of cfg by renaming like cert-manager or kubelet do.
+func rotateCert(t *testing.T, cfg *Cfg, certPEM []byte, keyPEM []byte) {
+	for path, data := range map[string][]byte{cfg.TLSCertFile: certPEM, cfg.TLSKeyFile: keyPEM} {
+		tmp := path + ".tmp"
+		require.NoError(t, os.WriteFile(tmp, data, 0o600))
+		require.NoError(t, os.Rename(tmp, path))
+	}
+}
+
+func TestCertReload(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		ca := newTestCA(t)
+		cfg := NewCfg()
+		cfg.DisableTLSCertReload = disabled
+		writeServerCert(t, ca, cfg, t.TempDir())
+		base := startHttpd(t, cfg, func(*Httpd) {})
+
+		client := newTLSClient(t, ca, nil, 0)
+		client.Transport.(*http.Transport).DisableKeepAlives = true
+		servedCert := func() *x509.Certificate {
+			resp, err := client.Get(base + "/healthz")
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			return resp.TLS.PeerCertificates[0]
+		}
+		oldCert := servedCert()
+
+		// invalid files are ignored and the current cert is kept
+		rotateCert(t, cfg, []byte("invalid"), []byte("invalid"))
+		time.Sleep(2 * certReloadDebounce)
+		require.Equal(t, oldCert.SerialNumber, servedCert().SerialNumber)
+
+		certPEM, keyPEM := ca.issue(t, "rotated")
+		rotateCert(t, cfg, certPEM, keyPEM)
+		if disabled {
+			time.Sleep(2 * certReloadDebounce)
+			require.Equal(t, oldCert.SerialNumber, servedCert().SerialNumber)
+			continue
+		}
+		require.Eventually(t, func() bool {
+			return servedCert().Subject.CommonName == "rotated"
+		}, 3*time.Second, 50*time.Millisecond)
+	}
+}
+
+func TestCertReloaderSymlinkSwap(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	// files are symlinks to a versioned directory, rotated by swapping the directory symlink
+	writeVersion := func(version string, cn string) {
+		vdir := filepath.Join(dir, version)
+		require.NoError(t, os.Mkdir(vdir, 0o700))
+		certPEM, keyPEM := ca.issue(t, cn)
+		require.NoError(t, os.WriteFile(filepath.Join(vdir, "tls.crt"), certPEM, 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(vdir, "tls.key"), keyPEM, 0o600))
+		tmp := filepath.Join(dir, "..data_tmp")
+		require.NoError(t, os.Symlink(version, tmp))
+		require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+	}
+	writeVersion("v1", "v1")
+	for _, name := range []string{"tls.crt", "tls.key"} {
+		require.NoError(t, os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)))
+	}
+
+	cr, err := newCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	require.NoError(t, err)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	reloaded := make(chan error, 1)
+	require.NoError(t, cr.watch(stopCh, func(err error) {
+		select {
+		case reloaded <- err:
+		default:
+		}
+	}))
+
+	cn := func() string {
+		cert, err := cr.GetCertificate(nil)
+		require.NoError(t, err)
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return parsed.Subject.CommonName
+	}
+	require.Equal(t, "v1", cn())
+
+	writeVersion("v2", "v2")
+	select {
+	case err = <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("cert is not reloaded after symlink swapped")
+	}
+	require.Equal(t, "v2", cn())
+}
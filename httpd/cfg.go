@@ -1,7 +1,10 @@
 package httpd
 
 import (
+	"net/http"
 	"time"
+
+	"github.com/donkeywon/golib/errs"
 )
 
 const (
@@ -9,14 +12,112 @@ const (
 	DefaultReadTimeout       = time.Second
 	DefaultReadHeaderTimeout = time.Second
 	DefaultIdleTimeout       = time.Second
+	DefaultShutdownTimeout   = 10 * time.Second
+	DefaultTLSMinVersion     = "1.2"
+
+	DefaultDisableTLSCertReload = false
+
+	DefaultAuthHMACMaxSkew      = 5 * time.Minute
+	DefaultAuthHMACMaxBodyBytes = 10 << 20
+
+	DefaultAccessLogSampleRatio = 1.0
+
+	DefaultCORSMaxAge = 10 * time.Minute
+
+	DefaultRateLimitKey = RateLimitKeyIP
+
+	DefaultCompressMinSize = 1024
+
+	DefaultLivenessPath       = "GET /healthz"
+	DefaultReadinessPath      = "GET /readyz"
+	DefaultHealthCheckTimeout = 5 * time.Second
+
+	DefaultSSEKeepAliveInterval = 15 * time.Second
+
+	DefaultWSPingInterval   = 30 * time.Second
+	DefaultWSPongTimeout    = 60 * time.Second
+	DefaultWSWriteTimeout   = 10 * time.Second
+	DefaultWSMaxMessageSize = 1 << 20
 )
 
 type Cfg struct {
-	Addr              string        `env:"HTTPD_ADDR"                flag-long:"httpd-addr"                yaml:"addr"      validate:"required" flag-description:"http listen address"`
+	Addr              string        `env:"HTTPD_ADDR"                flag-long:"httpd-addr"                yaml:"addr"      validate:"required_without=Addrs" flag-description:"http listen address, host:port, unix:///path/to/file.sock or systemd://[name]"`
+	Addrs             []string      `env:"HTTPD_ADDRS"               flag-long:"httpd-addrs"               yaml:"addrs"                                       flag-description:"additional http listen addresses, same format as addr"`
 	WriteTimeout      time.Duration `env:"HTTPD_WRITE_TIMEOUT"       flag-long:"httpd-write-timeout"       yaml:"writeTimeout"                  flag-description:"maximum duration before timing out writes of the response"`
 	ReadTimeout       time.Duration `env:"HTTPD_READ_TIMEOUT"        flag-long:"httpd-read-timeout"        yaml:"readTimeout"                   flag-description:"maximum duration for reading the entire request, including the body. A zero or negative value means there will be no timeout."`
 	ReadHeaderTimeout time.Duration `env:"HTTPD_READ_HEADER_TIMEOUT" flag-long:"httpd-read-header-timeout" yaml:"readHeaderTimeout"             flag-description:"the amount of time allowed to read request headers"`
 	IdleTimeout       time.Duration `env:"HTTPD_IDLE_TIMEOUT"        flag-long:"httpd-idle-timeout"        yaml:"idleTimeout"                   flag-description:"maximum amount of time to wait for the next request when keep-alives are enabled"`
+	ShutdownTimeout   time.Duration `env:"HTTPD_SHUTDOWN_TIMEOUT"    flag-long:"httpd-shutdown-timeout"    yaml:"shutdownTimeout"               flag-description:"maximum duration to wait for in-flight requests to finish when stopping, connections are closed forcibly after that. A zero or negative value means close immediately."`
+
+	UnixSocketMode  string `env:"HTTPD_UNIX_SOCKET_MODE"  flag-long:"httpd-unix-socket-mode"  yaml:"unixSocketMode"  flag-description:"file mode of unix socket in octal, e.g. 0660"`
+	UnixSocketOwner string `env:"HTTPD_UNIX_SOCKET_OWNER" flag-long:"httpd-unix-socket-owner" yaml:"unixSocketOwner" flag-description:"owner user name of unix socket"`
+	UnixSocketGroup string `env:"HTTPD_UNIX_SOCKET_GROUP" flag-long:"httpd-unix-socket-group" yaml:"unixSocketGroup" flag-description:"owner group name of unix socket"`
+
+	AuthBasicUsersFile   string        `env:"HTTPD_AUTH_BASIC_USERS_FILE"    flag-long:"httpd-auth-basic-users-file"    yaml:"authBasicUsersFile"   validate:"omitempty,file" flag-description:"file of basic auth users, user:password per line, used by Auth middleware"`
+	AuthBearerTokensFile string        `env:"HTTPD_AUTH_BEARER_TOKENS_FILE"  flag-long:"httpd-auth-bearer-tokens-file"  yaml:"authBearerTokensFile" validate:"omitempty,file" flag-description:"file of static bearer tokens, one token per line, used by Auth middleware"`
+	AuthHMACKeysFile     string        `env:"HTTPD_AUTH_HMAC_KEYS_FILE"      flag-long:"httpd-auth-hmac-keys-file"      yaml:"authHMACKeysFile"     validate:"omitempty,file" flag-description:"file of hmac keys, keyID:secret per line, used by Auth middleware"`
+	AuthHMACMaxSkew      time.Duration `env:"HTTPD_AUTH_HMAC_MAX_SKEW"       flag-long:"httpd-auth-hmac-max-skew"       yaml:"authHMACMaxSkew"                                flag-description:"maximum allowed clock skew of hmac signed request timestamp"`
+	AuthHMACMaxBodyBytes int64         `env:"HTTPD_AUTH_HMAC_MAX_BODY_BYTES" flag-long:"httpd-auth-hmac-max-body-bytes" yaml:"authHMACMaxBodyBytes" validate:"gte=0"          flag-description:"maximum body size in bytes of hmac signed request, body is read into memory to verify signature, zero means no limit"`
+
+	AccessLogExcludePaths []string      `env:"HTTPD_ACCESS_LOG_EXCLUDE_PATHS" flag-long:"httpd-access-log-exclude-paths" yaml:"accessLogExcludePaths"                               flag-description:"path patterns not to log, e.g. /metrics, /static/*.js, or /debug/ for all paths under it, error and slow requests are always logged"`
+	AccessLogSampleRatio  float64       `env:"HTTPD_ACCESS_LOG_SAMPLE_RATIO"  flag-long:"httpd-access-log-sample-ratio"  yaml:"accessLogSampleRatio"  validate:"gte=0,lte=1" flag-description:"ratio of 2xx requests to log, between 0 and 1, error and slow requests are always logged"`
+	SlowRequestThreshold  time.Duration `env:"HTTPD_SLOW_REQUEST_THRESHOLD"   flag-long:"httpd-slow-request-threshold"   yaml:"slowRequestThreshold"                         flag-description:"requests cost longer than this are logged at warn level with request headers, zero means disabled"`
+
+	HideStackTrace bool `env:"HTTPD_HIDE_STACK_TRACE" flag-long:"httpd-hide-stack-trace" yaml:"hideStackTrace" flag-description:"hide stack trace of panic and message of 5xx error from clients, they are still logged"`
+
+	OpenAPIPath string `env:"HTTPD_OPENAPI_PATH" flag-long:"httpd-openapi-path" yaml:"openAPIPath" flag-description:"path to serve generated OpenAPI 3 document of registered routes with Auth, e.g. GET /openapi.json, disabled when empty"`
+	RoutesPath  string `env:"HTTPD_ROUTES_PATH"  flag-long:"httpd-routes-path"  yaml:"routesPath"  flag-description:"path to serve registered route list with Auth, e.g. GET /routes, disabled when empty"`
+
+	LivenessPath       string        `env:"HTTPD_LIVENESS_PATH"        flag-long:"httpd-liveness-path"        yaml:"livenessPath"       flag-description:"path to serve liveness checks without Auth, disabled when empty, disabled by default in named servers"`
+	ReadinessPath      string        `env:"HTTPD_READINESS_PATH"       flag-long:"httpd-readiness-path"       yaml:"readinessPath"      flag-description:"path to serve readiness checks without Auth, it fails as soon as shutdown starts, disabled when empty, disabled by default in named servers"`
+	HealthCheckTimeout time.Duration `env:"HTTPD_HEALTH_CHECK_TIMEOUT" flag-long:"httpd-health-check-timeout" yaml:"healthCheckTimeout" flag-description:"maximum duration to wait for liveness or readiness checks, a check not returned in time is failed"`
+
+	CORSAllowedOrigins   []string      `env:"HTTPD_CORS_ALLOWED_ORIGINS"   flag-long:"httpd-cors-allowed-origins"   yaml:"corsAllowedOrigins"   flag-description:"origins allowed to make cross-origin requests, supports wildcard, e.g. * or https://*.example.com, * can not be used with credentials, CORS is disabled when empty"`
+	CORSAllowedMethods   []string      `env:"HTTPD_CORS_ALLOWED_METHODS"   flag-long:"httpd-cors-allowed-methods"   yaml:"corsAllowedMethods"   flag-description:"methods allowed in cross-origin requests"`
+	CORSAllowedHeaders   []string      `env:"HTTPD_CORS_ALLOWED_HEADERS"   flag-long:"httpd-cors-allowed-headers"   yaml:"corsAllowedHeaders"   flag-description:"headers allowed in cross-origin requests, reflect request headers when empty"`
+	CORSExposedHeaders   []string      `env:"HTTPD_CORS_EXPOSED_HEADERS"   flag-long:"httpd-cors-exposed-headers"   yaml:"corsExposedHeaders"   flag-description:"response headers exposed to cross-origin requests"`
+	CORSAllowCredentials bool          `env:"HTTPD_CORS_ALLOW_CREDENTIALS" flag-long:"httpd-cors-allow-credentials" yaml:"corsAllowCredentials" flag-description:"allow cross-origin requests with credentials"`
+	CORSMaxAge           time.Duration `env:"HTTPD_CORS_MAX_AGE"           flag-long:"httpd-cors-max-age"           yaml:"corsMaxAge"           flag-description:"how long the results of a preflight request can be cached"`
+
+	RateLimit      float64 `env:"HTTPD_RATE_LIMIT"       flag-long:"httpd-rate-limit"       yaml:"rateLimit"      validate:"gte=0"                                      flag-description:"maximum requests per second of each client, responds 429 when exceeded, zero means no limit"`
+	RateLimitBurst int     `env:"HTTPD_RATE_LIMIT_BURST" flag-long:"httpd-rate-limit-burst" yaml:"rateLimitBurst" validate:"gte=0"                                      flag-description:"maximum burst requests of each client, default is rate limit rounded up"`
+	RateLimitKey   string  `env:"HTTPD_RATE_LIMIT_KEY"   flag-long:"httpd-rate-limit-key"   yaml:"rateLimitKey"   validate:"omitempty,oneof=ip auth|startswith=header:" flag-description:"how to identify a client for rate limit, ip, auth or header:<Name>, auth identifies clients authenticated by Auth middleware by identity and others by ip, header:<Name> identifies clients by the header value and others by ip, it is only safe behind a trusted proxy which sets the header since clients can forge it"`
+	MaxInFlight    int     `env:"HTTPD_MAX_IN_FLIGHT"    flag-long:"httpd-max-in-flight"    yaml:"maxInFlight"    validate:"gte=0"                                      flag-description:"maximum requests being served concurrently, responds 503 when exceeded, zero means no limit"`
+
+	EnableCompression    bool     `env:"HTTPD_ENABLE_COMPRESSION"     flag-long:"httpd-enable-compression"     yaml:"enableCompression"                                                  flag-description:"compress response body with encoding negotiated by Accept-Encoding"`
+	CompressEncodings    []string `env:"HTTPD_COMPRESS_ENCODINGS"     flag-long:"httpd-compress-encodings"     yaml:"compressEncodings"    validate:"dive,oneof=zstd gzip deflate" flag-description:"supported encodings in order of preference, zstd gzip or deflate"`
+	CompressMinSize      int      `env:"HTTPD_COMPRESS_MIN_SIZE"      flag-long:"httpd-compress-min-size"      yaml:"compressMinSize"      validate:"gte=0"                        flag-description:"minimum response body size in bytes to compress"`
+	CompressContentTypes []string `env:"HTTPD_COMPRESS_CONTENT_TYPES" flag-long:"httpd-compress-content-types" yaml:"compressContentTypes"                                       flag-description:"response content types to compress, supports wildcard subtype, e.g. text/*"`
+
+	EnableH2C                 bool   `env:"HTTPD_ENABLE_H2C"                   flag-long:"httpd-enable-h2c"                   yaml:"enableH2C"                                                          flag-description:"serve http/2 cleartext along with http/1.1 on the same listener when tls is not enabled"`
+	HTTP2MaxConcurrentStreams uint32 `env:"HTTPD_HTTP2_MAX_CONCURRENT_STREAMS" flag-long:"httpd-http2-max-concurrent-streams" yaml:"http2MaxConcurrentStreams"                                          flag-description:"maximum concurrent streams of each http/2 connection, zero means default 250"`
+	HTTP2MaxReadFrameSize     uint32 `env:"HTTPD_HTTP2_MAX_READ_FRAME_SIZE"    flag-long:"httpd-http2-max-read-frame-size"    yaml:"http2MaxReadFrameSize"     validate:"omitempty,gte=16384,lte=16777215" flag-description:"largest http/2 frame size the server is willing to read, between 16384 and 16777215, zero means default 1MB"`
+
+	MaxBodyBytes int64 `env:"HTTPD_MAX_BODY_BYTES" flag-long:"httpd-max-body-bytes" yaml:"maxBodyBytes" validate:"gte=0" flag-description:"maximum request body size in bytes, responds 413 when exceeded, zero means no limit"`
+
+	// Routes overrides timeouts and body size limit by route pattern, e.g. GET /debug/pprof/profile,
+	// only configurable by config file.
+	Routes map[string]*RouteCfg `yaml:"routes"`
+
+	SSEKeepAliveInterval time.Duration `env:"HTTPD_SSE_KEEP_ALIVE_INTERVAL" flag-long:"httpd-sse-keep-alive-interval" yaml:"sseKeepAliveInterval" flag-description:"interval of keepalive comments sent to server-sent events streams, zero means disabled"`
+
+	WSPingInterval   time.Duration `env:"HTTPD_WS_PING_INTERVAL"    flag-long:"httpd-ws-ping-interval"    yaml:"wsPingInterval"                    flag-description:"interval of pings sent to websocket connections, zero means disabled"`
+	WSPongTimeout    time.Duration `env:"HTTPD_WS_PONG_TIMEOUT"     flag-long:"httpd-ws-pong-timeout"     yaml:"wsPongTimeout"                     flag-description:"websocket connection is closed if no pong or message received in this duration, should be longer than ping interval, zero means no timeout"`
+	WSWriteTimeout   time.Duration `env:"HTTPD_WS_WRITE_TIMEOUT"    flag-long:"httpd-ws-write-timeout"    yaml:"wsWriteTimeout"                    flag-description:"maximum duration of websocket handshake and writing a message"`
+	WSMaxMessageSize int64         `env:"HTTPD_WS_MAX_MESSAGE_SIZE" flag-long:"httpd-ws-max-message-size" yaml:"wsMaxMessageSize" validate:"gte=0" flag-description:"maximum size in bytes of websocket message read from client, zero means no limit"`
+	WSAllowedOrigins []string      `env:"HTTPD_WS_ALLOWED_ORIGINS"  flag-long:"httpd-ws-allowed-origins"  yaml:"wsAllowedOrigins"                  flag-description:"origins allowed to open websocket connections besides the same host, supports wildcard, e.g. https://*.example.com, * is ignored"`
+
+	// Servers is additional named server instances, e.g. admin, only configurable by config file.
+	// Fields of Servers in a named server cfg are ignored.
+	Servers map[string]*Cfg `yaml:"servers" validate:"dive"`
+
+	TLSCertFile     string   `env:"HTTPD_TLS_CERT_FILE"      flag-long:"httpd-tls-cert-file"      yaml:"tlsCertFile"     validate:"required_with=TLSKeyFile"                 flag-description:"tls certificate file path, serve https when both cert and key file are set"`
+	TLSKeyFile      string   `env:"HTTPD_TLS_KEY_FILE"       flag-long:"httpd-tls-key-file"       yaml:"tlsKeyFile"      validate:"required_with=TLSCertFile"                flag-description:"tls private key file path, serve https when both cert and key file are set"`
+	TLSClientCAFile string   `env:"HTTPD_TLS_CLIENT_CA_FILE" flag-long:"httpd-tls-client-ca-file" yaml:"tlsClientCAFile" validate:"omitempty,file"                           flag-description:"ca file path used to verify client certificates, enable mutual tls when set"`
+	TLSMinVersion   string   `env:"HTTPD_TLS_MIN_VERSION"    flag-long:"httpd-tls-min-version"    yaml:"tlsMinVersion"   validate:"omitempty,oneof=1.0 1.1 1.2 1.3"          flag-description:"minimum tls version, one of 1.0 1.1 1.2 1.3"`
+	TLSCipherSuites []string `env:"HTTPD_TLS_CIPHER_SUITES"  flag-long:"httpd-tls-cipher-suites"  yaml:"tlsCipherSuites"                                                     flag-description:"allowed tls cipher suites for tls 1.2 and below, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, use go defaults when empty"`
+
+	DisableTLSCertReload bool `env:"HTTPD_DISABLE_TLS_CERT_RELOAD" flag-long:"httpd-disable-tls-cert-reload" yaml:"disableTLSCertReload" flag-description:"disable watching tls cert and key file and reloading them when rotated on disk"`
 }
 
 func NewCfg() *Cfg {
@@ -25,5 +126,79 @@ func NewCfg() *Cfg {
 		ReadTimeout:       DefaultReadTimeout,
 		ReadHeaderTimeout: DefaultReadHeaderTimeout,
 		IdleTimeout:       DefaultIdleTimeout,
+		ShutdownTimeout:   DefaultShutdownTimeout,
+		TLSMinVersion:     DefaultTLSMinVersion,
+
+		DisableTLSCertReload: DefaultDisableTLSCertReload,
+
+		AuthHMACMaxSkew:      DefaultAuthHMACMaxSkew,
+		AuthHMACMaxBodyBytes: DefaultAuthHMACMaxBodyBytes,
+
+		AccessLogSampleRatio: DefaultAccessLogSampleRatio,
+
+		CORSAllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		CORSExposedHeaders: []string{HeaderRequestID},
+		CORSMaxAge:         DefaultCORSMaxAge,
+
+		RateLimitKey: DefaultRateLimitKey,
+
+		CompressEncodings:    []string{EncodingZstd, EncodingGzip, EncodingDeflate},
+		CompressMinSize:      DefaultCompressMinSize,
+		CompressContentTypes: []string{"text/*", "application/json", "application/yaml", "application/xml", "application/javascript", "image/svg+xml"},
+
+		LivenessPath:       DefaultLivenessPath,
+		ReadinessPath:      DefaultReadinessPath,
+		HealthCheckTimeout: DefaultHealthCheckTimeout,
+
+		SSEKeepAliveInterval: DefaultSSEKeepAliveInterval,
+
+		WSPingInterval:   DefaultWSPingInterval,
+		WSPongTimeout:    DefaultWSPongTimeout,
+		WSWriteTimeout:   DefaultWSWriteTimeout,
+		WSMaxMessageSize: DefaultWSMaxMessageSize,
+	}
+}
+
+// UnmarshalYAML fill default values before unmarshal, so that named server cfg in Servers also has default values.
+// Liveness and readiness endpoints are not protected by Auth, so they are served by named servers only if set explicitly.
+func (c *Cfg) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Cfg
+	cfg := NewCfg()
+	err := unmarshal((*plain)(cfg))
+	if err != nil {
+		return err
+	}
+
+	var explicit struct {
+		Servers map[string]map[string]interface{} `yaml:"servers"`
+	}
+	err = unmarshal(&explicit)
+	if err != nil {
+		return err
+	}
+	for name, sc := range cfg.Servers {
+		if sc == nil {
+			continue
+		}
+		if _, exists := explicit.Servers[name]["livenessPath"]; !exists {
+			sc.LivenessPath = ""
+		}
+		if _, exists := explicit.Servers[name]["readinessPath"]; !exists {
+			sc.ReadinessPath = ""
+		}
+	}
+
+	*c = *cfg
+	return nil
+}
+
+// validate checks constraints across fields which can not be expressed by validate tags.
+func (c *Cfg) validate() error {
+	if c.RateLimitKey == RateLimitKeyHeaderPrefix {
+		return errs.New("rate limit key header name is empty, e.g. header:X-User-ID")
+	}
+	if c.CORSAllowCredentials && containsStr(c.CORSAllowedOrigins, "*") {
+		return errs.New("cors allowed origin * can not be used with credentials, list the origins instead")
 	}
+	return nil
 }
@@ -0,0 +1,31 @@
+package httpd
+
+import (
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCfgUnmarshalYAMLHealthPaths(t *testing.T) {
+	cfg := &Cfg{}
+	err := yaml.Unmarshal([]byte(`
+addr: 127.0.0.1:8080
+servers:
+  admin:
+    addr: 127.0.0.1:8081
+  probe:
+    addr: 127.0.0.1:8082
+    livenessPath: GET /livez
+    readinessPath: GET /readyz
+`), cfg)
+	require.NoError(t, err)
+
+	require.Equal(t, DefaultLivenessPath, cfg.LivenessPath)
+	require.Equal(t, DefaultReadinessPath, cfg.ReadinessPath)
+	require.Empty(t, cfg.Servers["admin"].LivenessPath)
+	require.Empty(t, cfg.Servers["admin"].ReadinessPath)
+	require.Equal(t, DefaultWriteTimeout, cfg.Servers["admin"].WriteTimeout)
+	require.Equal(t, "GET /livez", cfg.Servers["probe"].LivenessPath)
+	require.Equal(t, DefaultReadinessPath, cfg.Servers["probe"].ReadinessPath)
+}
@@ -0,0 +1,315 @@
+package httpd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+
+	headerAcceptEncoding  = "Accept-Encoding"
+	headerContentEncoding = "Content-Encoding"
+	headerContentLength   = "Content-Length"
+	headerContentType     = "Content-Type"
+)
+
+type CompressOptions struct {
+	// Encodings in order of preference when client accepts several with same q value.
+	Encodings []string
+	// MinSize is the minimum response body size to compress, responses flushed before reaching it are still compressed.
+	MinSize int
+	// ContentTypes supports wildcard subtype, e.g. text/*
+	ContentTypes []string
+}
+
+func compressOptionsFromCfg(cfg *Cfg) *CompressOptions {
+	return &CompressOptions{
+		Encodings:    cfg.CompressEncodings,
+		MinSize:      cfg.CompressMinSize,
+		ContentTypes: cfg.CompressContentTypes,
+	}
+}
+
+type flushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type encoder struct {
+	pool sync.Pool
+	new  func(w io.Writer) flushWriteCloser
+	// reset must be called before reusing a pooled writer
+	reset func(fwc flushWriteCloser, w io.Writer)
+}
+
+func (e *encoder) get(w io.Writer) flushWriteCloser {
+	fwc, ok := e.pool.Get().(flushWriteCloser)
+	if !ok {
+		return e.new(w)
+	}
+	e.reset(fwc, w)
+	return fwc
+}
+
+func (e *encoder) put(fwc flushWriteCloser) {
+	e.pool.Put(fwc)
+}
+
+var encoders = map[string]*encoder{
+	EncodingGzip: {
+		new:   func(w io.Writer) flushWriteCloser { return gzip.NewWriter(w) },
+		reset: func(fwc flushWriteCloser, w io.Writer) { fwc.(*gzip.Writer).Reset(w) },
+	},
+	EncodingDeflate: {
+		new:   func(w io.Writer) flushWriteCloser { return zlib.NewWriter(w) },
+		reset: func(fwc flushWriteCloser, w io.Writer) { fwc.(*zlib.Writer).Reset(w) },
+	},
+	EncodingZstd: {
+		new: func(w io.Writer) flushWriteCloser {
+			zw, _ := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+			return zw
+		},
+		reset: func(fwc flushWriteCloser, w io.Writer) { fwc.(*zstd.Encoder).Reset(w) },
+	},
+}
+
+// Compress returns a middleware which compresses response body with encoding negotiated by Accept-Encoding.
+// Responses already have Content-Encoding, upgrade and range requests are not compressed.
+func Compress(opts *CompressOptions) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			compress(opts, next, w, r)
+		})
+	}
+}
+
+func (s *Server) compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts := s.st.Load().compressOpts
+		if opts == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		compress(opts, next, w, r)
+	})
+}
+
+func compress(opts *CompressOptions, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") != "" || r.Header.Get("Range") != "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	enc := negotiateEncoding(r.Header.Get(headerAcceptEncoding), opts.Encodings)
+	if enc == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressResponseWriter{
+		ResponseWriter: w,
+		opts:           opts,
+		encoding:       enc,
+		statusCode:     http.StatusOK,
+	}
+	next.ServeHTTP(cw, r)
+
+	// not deferred, buffered body is dropped if panic occurred, let the recover middleware respond
+	compressed := cw.close()
+	if compressed {
+		AddLogFields(r.Context(), "content_encoding", enc, "resp_raw_size", cw.nRaw)
+	}
+}
+
+// negotiateEncoding picks the encoding with highest q value in Accept-Encoding, ties are broken by order of supported.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qs := parseAcceptEncoding(acceptEncoding)
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		if encoders[enc] == nil {
+			continue
+		}
+		q, exists := qs[enc]
+		if !exists {
+			q, exists = qs["*"]
+		}
+		if exists && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// parseAcceptEncoding returns q value of each encoding in Accept-Encoding, invalid ones are ignored.
+func parseAcceptEncoding(acceptEncoding string) map[string]float64 {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			pq, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = pq
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	return qs
+}
+
+func contentTypeAllowed(allowed []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if a == mediaType {
+			return true
+		}
+		if prefix, found := strings.CutSuffix(a, "/*"); found && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponseWriter buffers the beginning of response body until MinSize is reached
+// to decide whether to compress, it must be closed after handler returns.
+type compressResponseWriter struct {
+	http.ResponseWriter
+
+	opts       *CompressOptions
+	encoding   string
+	statusCode int
+	buf        []byte
+	decided    bool
+	fwc        flushWriteCloser
+	nRaw       int
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if statusCode >= 100 && statusCode < 200 {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.statusCode = statusCode
+}
+
+func (cw *compressResponseWriter) Write(data []byte) (int, error) {
+	cw.nRaw += len(data)
+	if !cw.decided {
+		cw.buf = append(cw.buf, data...)
+		if len(cw.buf) < cw.opts.MinSize {
+			return len(data), nil
+		}
+		return len(data), cw.decide(false)
+	}
+	if cw.fwc != nil {
+		return cw.fwc.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+func (cw *compressResponseWriter) decide(flushing bool) error {
+	cw.decided = true
+
+	h := cw.ResponseWriter.Header()
+	if h.Get(headerContentType) == "" && len(cw.buf) > 0 {
+		h.Set(headerContentType, http.DetectContentType(cw.buf))
+	}
+
+	if cw.shouldCompress(flushing) {
+		h.Del(headerContentLength)
+		h.Set(headerContentEncoding, cw.encoding)
+		h.Add(headerVary, headerAcceptEncoding)
+		if etag := h.Get(headerETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// compressed body is another representation, a strong validator must not name both
+			h.Set(headerETag, "W/"+etag)
+		}
+		cw.fwc = encoders[cw.encoding].get(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.fwc != nil {
+		_, err = cw.fwc.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressResponseWriter) shouldCompress(flushing bool) bool {
+	switch cw.statusCode {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	h := cw.ResponseWriter.Header()
+	if h.Get(headerContentEncoding) != "" {
+		return false
+	}
+	if !flushing && len(cw.buf) < cw.opts.MinSize {
+		return false
+	}
+	return contentTypeAllowed(cw.opts.ContentTypes, h.Get(headerContentType))
+}
+
+func (cw *compressResponseWriter) close() bool {
+	if !cw.decided {
+		_ = cw.decide(false)
+	}
+	if cw.fwc == nil {
+		return false
+	}
+	_ = cw.fwc.Close()
+	encoders[cw.encoding].put(cw.fwc)
+	cw.fwc = nil
+	return true
+}
+
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if cw.fwc != nil {
+		_ = cw.fwc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	return cw.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
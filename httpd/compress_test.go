@@ -0,0 +1,168 @@
+package httpd
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressETag(t *testing.T) {
+	cfg := NewCfg()
+	cfg.EnableCompression = true
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /text", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerETag, r.URL.Query().Get("etag"))
+			w.Header().Set(headerContentType, "text/plain")
+			_, _ = w.Write([]byte(strings.Repeat("a", 2*DefaultCompressMinSize)))
+		})
+	})
+
+	tests := []struct {
+		name     string
+		encoding string
+		etag     string
+		wantEnc  string
+		wantETag string
+	}{
+		{name: "identity", encoding: "identity", etag: `"v1"`, wantETag: `"v1"`},
+		{name: "gzip", encoding: EncodingGzip, etag: `"v1"`, wantEnc: EncodingGzip, wantETag: `W/"v1"`},
+		{name: "zstd", encoding: EncodingZstd, etag: `"v1"`, wantEnc: EncodingZstd, wantETag: `W/"v1"`},
+		{name: "weak kept", encoding: EncodingGzip, etag: `W/"v1"`, wantEnc: EncodingGzip, wantETag: `W/"v1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, base+"/text?etag="+tt.etag, nil)
+			require.NoError(t, err)
+			req.Header.Set(headerAcceptEncoding, tt.encoding)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			require.Equal(t, tt.wantEnc, resp.Header.Get(headerContentEncoding))
+			require.Equal(t, tt.wantETag, resp.Header.Get(headerETag))
+		})
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingZstd, EncodingGzip, EncodingDeflate}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: ""},
+		{accept: "identity", want: ""},
+		{accept: "gzip, deflate", want: EncodingGzip},
+		{accept: "gzip, zstd", want: EncodingZstd},
+		{accept: "zstd;q=0.5, gzip;q=0.8", want: EncodingGzip},
+		{accept: "GZIP", want: EncodingGzip},
+		{accept: "*", want: EncodingZstd},
+		{accept: "*;q=0.1, gzip;q=0", want: EncodingZstd},
+		{accept: "gzip;q=0", want: ""},
+		{accept: "gzip;q=invalid, deflate", want: EncodingDeflate},
+		{accept: "br", want: ""},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, negotiateEncoding(tt.accept, supported), tt.accept)
+	}
+	require.Equal(t, EncodingGzip, negotiateEncoding("zstd;q=0.5, gzip;q=0.5", []string{EncodingGzip, EncodingZstd}))
+}
+
+func TestContentTypeAllowed(t *testing.T) {
+	allowed := []string{"text/*", "application/json"}
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{contentType: "text/plain; charset=utf-8", want: true},
+		{contentType: "text/html", want: true},
+		{contentType: "application/json", want: true},
+		{contentType: "application/json-seq"},
+		{contentType: "textual/plain"},
+		{contentType: "image/png"},
+		{contentType: ""},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, contentTypeAllowed(allowed, tt.contentType), tt.contentType)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	cfg := NewCfg()
+	cfg.EnableCompression = true
+	large := strings.Repeat("compress me ", DefaultCompressMinSize)
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		s.HandleFunc("GET /text", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(large[:len(large)/2]))
+			_, _ = w.Write([]byte(large[len(large)/2:]))
+		})
+		s.HandleFunc("GET /small", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("small"))
+		})
+		s.HandleFunc("GET /png", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerContentType, "image/png")
+			_, _ = w.Write([]byte(large))
+		})
+		s.HandleFunc("GET /flush", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerContentType, "text/plain")
+			_, _ = w.Write([]byte("event"))
+			w.(http.Flusher).Flush()
+		})
+	})
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		EncodingGzip:    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		EncodingDeflate: func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		EncodingZstd:    func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		"":              func(r io.Reader) (io.Reader, error) { return r, nil },
+	}
+	tests := []struct {
+		name    string
+		path    string
+		accept  string
+		header  http.Header
+		wantEnc string
+		want    string
+	}{
+		{name: "gzip", path: "/text", accept: EncodingGzip, wantEnc: EncodingGzip, want: large},
+		{name: "deflate", path: "/text", accept: EncodingDeflate, wantEnc: EncodingDeflate, want: large},
+		{name: "zstd", path: "/text", accept: "gzip, zstd", wantEnc: EncodingZstd, want: large},
+		{name: "not accepted", path: "/text", want: large},
+		{name: "range", path: "/text", accept: EncodingGzip, header: http.Header{"Range": {"bytes=0-1"}}, want: large},
+		{name: "below min size", path: "/small", accept: EncodingGzip, want: "small"},
+		{name: "content type not allowed", path: "/png", accept: EncodingGzip, want: large},
+		{name: "flushed below min size", path: "/flush", accept: EncodingGzip, wantEnc: EncodingGzip, want: "event"},
+	}
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, base+tt.path, nil)
+			require.NoError(t, err)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			if tt.accept != "" {
+				req.Header.Set(headerAcceptEncoding, tt.accept)
+			}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantEnc, resp.Header.Get(headerContentEncoding))
+			if tt.wantEnc != "" {
+				require.Equal(t, headerAcceptEncoding, resp.Header.Get(headerVary))
+			}
+			r, err := decoders[tt.wantEnc](resp.Body)
+			require.NoError(t, err)
+			body, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(body))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package httpd
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		conns: make(map[net.Conn]http.ConnState),
+	}
+}
+
+func (ct *connTracker) onStateChange(c net.Conn, state http.ConnState) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		delete(ct.conns, c)
+	case http.StateNew, http.StateActive, http.StateIdle:
+		ct.conns[c] = state
+	}
+}
+
+// activeCount returns the number of connections which are processing or about to process a request.
+func (ct *connTracker) activeCount() int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	n := 0
+	for _, state := range ct.conns {
+		if state != http.StateIdle {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerOrigin                        = "Origin"
+	headerVary                          = "Vary"
+	headerAccessControlRequestMethod    = "Access-Control-Request-Method"
+	headerAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	headerAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	headerAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	headerAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	headerAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+type CORSOptions struct {
+	// AllowedOrigins supports wildcard, e.g. * or https://*.example.com
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+func corsOptionsFromCfg(cfg *Cfg) *CORSOptions {
+	return &CORSOptions{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		ExposedHeaders:   cfg.CORSExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+}
+
+// CORS returns a middleware which answers preflight requests and sets CORS headers for allowed origins.
+// Preflight requests are answered by the middleware and never reach next handler.
+func CORS(opts *CORSOptions) MiddlewareFunc {
+	allowMethods := strings.Join(opts.AllowedMethods, ", ")
+	allowHeaders := strings.Join(opts.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := ""
+	if opts.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get(headerOrigin)
+			preflight := r.Method == http.MethodOptions && r.Header.Get(headerAccessControlRequestMethod) != ""
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add(headerVary, headerOrigin)
+			if preflight {
+				h.Add(headerVary, headerAccessControlRequestMethod)
+				h.Add(headerVary, headerAccessControlRequestHeaders)
+			}
+
+			matched := matchOrigin(opts.AllowedOrigins, origin)
+			if matched == "" {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if matched == "*" {
+				// any site can match *, never echo the origin with credentials for it
+				h.Set(headerAccessControlAllowOrigin, "*")
+			} else {
+				h.Set(headerAccessControlAllowOrigin, origin)
+				if opts.AllowCredentials {
+					h.Set(headerAccessControlAllowCredentials, "true")
+				}
+			}
+
+			if !preflight {
+				if exposeHeaders != "" {
+					h.Set(headerAccessControlExposeHeaders, exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if allowMethods != "" {
+				h.Set(headerAccessControlAllowMethods, allowMethods)
+			} else {
+				h.Set(headerAccessControlAllowMethods, r.Header.Get(headerAccessControlRequestMethod))
+			}
+			if allowHeaders != "" {
+				h.Set(headerAccessControlAllowHeaders, allowHeaders)
+			} else if reqHeaders := r.Header.Get(headerAccessControlRequestHeaders); reqHeaders != "" {
+				h.Set(headerAccessControlAllowHeaders, reqHeaders)
+			}
+			if maxAge != "" {
+				h.Set(headerAccessControlMaxAge, maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// matchOrigin returns the pattern in allowed matching origin, patterns other than * take precedence, empty if none.
+func matchOrigin(allowed []string, origin string) string {
+	matched := ""
+	for _, pattern := range allowed {
+		if pattern == "*" {
+			matched = pattern
+			continue
+		}
+		if strings.EqualFold(pattern, origin) {
+			return pattern
+		}
+		prefix, suffix, found := strings.Cut(pattern, "*")
+		if found && len(origin) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return pattern
+		}
+	}
+	return matched
+}
+
+func containsStr(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
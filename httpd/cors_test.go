@@ -0,0 +1,148 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("next"))
+	})
+
+	tests := []struct {
+		name        string
+		opts        *CORSOptions
+		method      string
+		origin      string
+		reqMethod   string
+		status      int
+		allowOrigin string
+		credentials string
+		reachNext   bool
+	}{
+		{
+			name:      "no origin",
+			opts:      &CORSOptions{AllowedOrigins: []string{"https://a.com"}},
+			method:    http.MethodGet,
+			status:    http.StatusOK,
+			reachNext: true,
+		},
+		{
+			name:        "exact origin",
+			opts:        &CORSOptions{AllowedOrigins: []string{"https://a.com"}},
+			method:      http.MethodGet,
+			origin:      "https://a.com",
+			status:      http.StatusOK,
+			allowOrigin: "https://a.com",
+			reachNext:   true,
+		},
+		{
+			name:      "origin not allowed",
+			opts:      &CORSOptions{AllowedOrigins: []string{"https://a.com"}},
+			method:    http.MethodGet,
+			origin:    "https://b.com",
+			status:    http.StatusOK,
+			reachNext: true,
+		},
+		{
+			name:        "wildcard subdomain",
+			opts:        &CORSOptions{AllowedOrigins: []string{"https://*.example.com"}},
+			method:      http.MethodGet,
+			origin:      "https://api.example.com",
+			status:      http.StatusOK,
+			allowOrigin: "https://api.example.com",
+			reachNext:   true,
+		},
+		{
+			name:      "wildcard subdomain not match",
+			opts:      &CORSOptions{AllowedOrigins: []string{"https://*.example.com"}},
+			method:    http.MethodGet,
+			origin:    "https://example.com.evil.com",
+			status:    http.StatusOK,
+			reachNext: true,
+		},
+		{
+			name:        "any origin",
+			opts:        &CORSOptions{AllowedOrigins: []string{"*"}},
+			method:      http.MethodGet,
+			origin:      "https://b.com",
+			status:      http.StatusOK,
+			allowOrigin: "*",
+			reachNext:   true,
+		},
+		{
+			name:        "credentials with listed origin",
+			opts:        &CORSOptions{AllowedOrigins: []string{"https://a.com", "*"}, AllowCredentials: true},
+			method:      http.MethodGet,
+			origin:      "https://a.com",
+			status:      http.StatusOK,
+			allowOrigin: "https://a.com",
+			credentials: "true",
+			reachNext:   true,
+		},
+		{
+			name:        "credentials never allowed for any origin",
+			opts:        &CORSOptions{AllowedOrigins: []string{"https://a.com", "*"}, AllowCredentials: true},
+			method:      http.MethodGet,
+			origin:      "https://evil.com",
+			status:      http.StatusOK,
+			allowOrigin: "*",
+			reachNext:   true,
+		},
+		{
+			name:        "preflight",
+			opts:        &CORSOptions{AllowedOrigins: []string{"https://a.com"}, AllowedMethods: []string{http.MethodPut}},
+			method:      http.MethodOptions,
+			origin:      "https://a.com",
+			reqMethod:   http.MethodPut,
+			status:      http.StatusNoContent,
+			allowOrigin: "https://a.com",
+		},
+		{
+			name:      "preflight origin not allowed",
+			opts:      &CORSOptions{AllowedOrigins: []string{"https://a.com"}},
+			method:    http.MethodOptions,
+			origin:    "https://b.com",
+			reqMethod: http.MethodPut,
+			status:    http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				r.Header.Set(headerOrigin, tt.origin)
+			}
+			if tt.reqMethod != "" {
+				r.Header.Set(headerAccessControlRequestMethod, tt.reqMethod)
+				r.Header.Set(headerAccessControlRequestHeaders, "X-Token")
+			}
+			w := httptest.NewRecorder()
+			CORS(tt.opts)(next).ServeHTTP(w, r)
+
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.allowOrigin, w.Header().Get(headerAccessControlAllowOrigin))
+			require.Equal(t, tt.credentials, w.Header().Get(headerAccessControlAllowCredentials))
+			require.Equal(t, tt.reachNext, w.Body.String() == "next")
+			if tt.reqMethod != "" && tt.allowOrigin != "" {
+				require.Equal(t, http.MethodPut, w.Header().Get(headerAccessControlAllowMethods))
+				require.Equal(t, "X-Token", w.Header().Get(headerAccessControlAllowHeaders))
+			}
+		})
+	}
+}
+
+func TestCORSCfgRejectsAnyOriginWithCredentials(t *testing.T) {
+	cfg := NewCfg()
+	cfg.CORSAllowedOrigins = []string{"*"}
+	cfg.CORSAllowCredentials = true
+	require.Error(t, cfg.validate())
+
+	cfg.CORSAllowedOrigins = []string{"https://a.com"}
+	require.NoError(t, cfg.validate())
+}
@@ -0,0 +1,144 @@
+package httpd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+
+	maxRequestIDLen = 128
+)
+
+type ctxKey int
+
+const (
+	ctxKeyAuthIdentity ctxKey = iota
+	ctxKeyRequestID
+	ctxKeyLogger
+	ctxKeyRoute
+	ctxKeyLogFields
+	ctxKeyPendingRateLimit
+	ctxKeyRouteTimeouts
+)
+
+// Logger is the logger of request scope, it carries request_id and server fields.
+type Logger interface {
+	Debug(msg string, kvs ...any)
+	Info(msg string, kvs ...any)
+	Warn(msg string, kvs ...any)
+	Error(msg string, err error, kvs ...any)
+}
+
+type reqLogger struct {
+	l   Logger
+	kvs []any
+}
+
+func (rl *reqLogger) with(kvs []any) []any {
+	return append(append(make([]any, 0, len(rl.kvs)+len(kvs)), kvs...), rl.kvs...)
+}
+
+func (rl *reqLogger) Debug(msg string, kvs ...any) {
+	rl.l.Debug(msg, rl.with(kvs)...)
+}
+
+func (rl *reqLogger) Info(msg string, kvs ...any) {
+	rl.l.Info(msg, rl.with(kvs)...)
+}
+
+func (rl *reqLogger) Warn(msg string, kvs ...any) {
+	rl.l.Warn(msg, rl.with(kvs)...)
+}
+
+func (rl *reqLogger) Error(msg string, err error, kvs ...any) {
+	rl.l.Error(msg, err, rl.with(kvs)...)
+}
+
+// AuthIdentity returns the identity authenticated by auth middlewares, e.g. basic:user.
+func AuthIdentity(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyAuthIdentity).(string)
+	return id
+}
+
+func withAuthIdentity(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKeyAuthIdentity, id)
+}
+
+// RequestID returns the request id of the request, pass it by X-Request-ID header when calling downstream.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyRequestID).(string)
+	return id
+}
+
+// LoggerFromCtx returns the logger of request scope, or the httpd logger if ctx is not a request context.
+func LoggerFromCtx(ctx context.Context) Logger {
+	l, ok := ctx.Value(ctxKeyLogger).(Logger)
+	if !ok {
+		return _h
+	}
+	return l
+}
+
+func withRequestID(ctx context.Context, id string, l Logger) context.Context {
+	ctx = context.WithValue(ctx, ctxKeyRequestID, id)
+	return context.WithValue(ctx, ctxKeyLogger, l)
+}
+
+// reqIDFromHeader accept request id from client only if it is reasonable, otherwise generate a new one.
+func reqIDFromHeader(id string) string {
+	if id == "" || len(id) > maxRequestIDLen {
+		return newRequestID()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return newRequestID()
+		}
+	}
+	return id
+}
+
+func newRequestID() string {
+	bs := make([]byte, 16)
+	_, _ = rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
+
+func withRoute(ctx context.Context, rt *route) context.Context {
+	return context.WithValue(ctx, ctxKeyRoute, rt)
+}
+
+func routeFromCtx(ctx context.Context) *route {
+	rt, _ := ctx.Value(ctxKeyRoute).(*route)
+	return rt
+}
+
+type logFieldsHolder struct {
+	mu  sync.Mutex
+	kvs []any
+}
+
+// AddLogFields adds fields to the access log of the request.
+func AddLogFields(ctx context.Context, kvs ...any) {
+	h, ok := ctx.Value(ctxKeyLogFields).(*logFieldsHolder)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	h.kvs = append(h.kvs, kvs...)
+	h.mu.Unlock()
+}
+
+func withLogFields(ctx context.Context) (context.Context, *logFieldsHolder) {
+	h := &logFieldsHolder{}
+	return context.WithValue(ctx, ctxKeyLogFields, h), h
+}
+
+func (h *logFieldsHolder) fields() []any {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.kvs
+}
@@ -0,0 +1,96 @@
+package httpd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordLogger struct {
+	msgs []string
+	kvs  [][]any
+}
+
+func (l *recordLogger) record(msg string, kvs []any) {
+	l.msgs = append(l.msgs, msg)
+	l.kvs = append(l.kvs, kvs)
+}
+
+func (l *recordLogger) Debug(msg string, kvs ...any) { l.record(msg, kvs) }
+
+func (l *recordLogger) Info(msg string, kvs ...any) { l.record(msg, kvs) }
+
+func (l *recordLogger) Warn(msg string, kvs ...any) { l.record(msg, kvs) }
+
+func (l *recordLogger) Error(msg string, _ error, kvs ...any) { l.record(msg, kvs) }
+
+func TestReqIDFromHeader(t *testing.T) {
+	for _, id := range []string{"abc-123", "0af7651916cd43dd8448eb211c80319c", strings.Repeat("a", maxRequestIDLen)} {
+		require.Equal(t, id, reqIDFromHeader(id))
+	}
+	for _, id := range []string{"", "a b", "a\nb", "中文", strings.Repeat("a", maxRequestIDLen+1)} {
+		got := reqIDFromHeader(id)
+		require.NotEqual(t, id, got)
+		require.Len(t, got, 32)
+	}
+	require.NotEqual(t, newRequestID(), newRequestID())
+}
+
+func TestReqLogger(t *testing.T) {
+	rl := &recordLogger{}
+	ctx := withRequestID(context.Background(), "id", &reqLogger{l: rl, kvs: []any{"request_id", "id"}})
+	require.Equal(t, "id", RequestID(ctx))
+
+	LoggerFromCtx(ctx).Info("msg", "k", "v")
+	require.Equal(t, []string{"msg"}, rl.msgs)
+	require.Equal(t, []any{"k", "v", "request_id", "id"}, rl.kvs[0])
+
+	// not a request context
+	require.Empty(t, RequestID(context.Background()))
+	require.NotNil(t, LoggerFromCtx(context.Background()))
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get(HeaderRequestID)))
+	}))
+	defer upstream.Close()
+
+	cfg := NewCfg()
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		s.HandleFunc("GET /id", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(RequestID(r.Context())))
+		})
+		require.NoError(t, s.HandleProxy("/proxy/", []string{upstream.URL}, nil))
+	})
+
+	get := func(path string, id string) (string, string) {
+		req, err := http.NewRequest(http.MethodGet, base+path, nil)
+		require.NoError(t, err)
+		if id != "" {
+			req.Header.Set(HeaderRequestID, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		return resp.Header.Get(HeaderRequestID), string(body)
+	}
+
+	for _, path := range []string{"/id", "/proxy/"} {
+		respID, body := get(path, "client-id")
+		require.Equal(t, "client-id", respID, path)
+		require.Equal(t, "client-id", body, path)
+
+		respID, body = get(path, "")
+		require.Len(t, respID, 32, path)
+		require.Equal(t, respID, body, path)
+	}
+}
@@ -0,0 +1,157 @@
+package httpd
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+const (
+	tagForm = "form"
+	tagPath = "path"
+	tagJSON = "json"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decodeForm decodes url values into struct pointed by v, field name is taken from form tag, then json tag, then field name.
+func decodeForm(values url.Values, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return decodeStruct(rv.Elem(), func(f reflect.StructField) ([]string, bool) {
+		vals, exists := values[formFieldName(f)]
+		return vals, exists
+	})
+}
+
+// decodePathValues decodes path wildcards into fields with path tag, e.g. `path:"id"` for pattern /users/{id}.
+func decodePathValues(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return decodeStruct(rv.Elem(), func(f reflect.StructField) ([]string, bool) {
+		name := f.Tag.Get(tagPath)
+		if name == "" || name == "-" {
+			return nil, false
+		}
+		val := r.PathValue(name)
+		return []string{val}, val != ""
+	})
+}
+
+func decodeStruct(rv reflect.Value, lookup func(reflect.StructField) ([]string, bool)) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		fv := rv.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			err := decodeStruct(fv, lookup)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		vals, exists := lookup(f)
+		if !exists || len(vals) == 0 {
+			continue
+		}
+		err := setField(fv, vals)
+		if err != nil {
+			return errs.Wrapf(err, "decode field %s fail", f.Name)
+		}
+	}
+	return nil
+}
+
+func formFieldName(f reflect.StructField) string {
+	for _, tag := range []string{tagForm, tagJSON} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+func setField(fv reflect.Value, vals []string) error {
+	switch fv.Kind() {
+	case reflect.Pointer:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setField(fv.Elem(), vals)
+	case reflect.Slice:
+		s := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			err := setValue(s.Index(i), val)
+			if err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	default:
+		return setValue(fv, vals[0])
+	}
+}
+
+func setValue(fv reflect.Value, val string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Pointer:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setValue(fv.Elem(), val)
+	default:
+		return errs.Errorf("unsupported field type: %s", fv.Type())
+	}
+	return nil
+}
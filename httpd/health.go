@@ -0,0 +1,181 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/donkeywon/golib/util/httpu"
+)
+
+const (
+	HealthStatusOk   = "ok"
+	HealthStatusFail = "fail"
+
+	shutdownCheckName = "shutdown"
+)
+
+var ErrShuttingDown = errors.New("shutting down")
+
+// CheckFunc reports unhealthy by returning an error, it should return in time when ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type CheckResult struct {
+	Status string `json:"status"          yaml:"status"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+	Cost   string `json:"cost"            yaml:"cost"`
+}
+
+type HealthResult struct {
+	Status string                  `json:"status"           yaml:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty" yaml:"checks,omitempty"`
+}
+
+type healthChecks struct {
+	mu     sync.RWMutex
+	checks map[string]CheckFunc
+}
+
+func newHealthChecks() *healthChecks {
+	return &healthChecks{checks: make(map[string]CheckFunc)}
+}
+
+func (hc *healthChecks) register(name string, check CheckFunc) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.checks[name] = check
+}
+
+func (hc *healthChecks) snapshot() map[string]CheckFunc {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	checks := make(map[string]CheckFunc, len(hc.checks))
+	for name, check := range hc.checks {
+		checks[name] = check
+	}
+	return checks
+}
+
+// runChecks runs all checks concurrently, a check not returned before timeout is failed.
+func runChecks(checks map[string]CheckFunc, timeout time.Duration) *HealthResult {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]*CheckResult, len(names))
+	wg := sync.WaitGroup{}
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check CheckFunc) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, checks[name])
+	}
+	wg.Wait()
+
+	hr := &HealthResult{Status: HealthStatusOk, Checks: make(map[string]*CheckResult, len(names))}
+	for i, name := range names {
+		hr.Checks[name] = results[i]
+		if results[i].Status != HealthStatusOk {
+			hr.Status = HealthStatusFail
+		}
+	}
+	return hr
+}
+
+func runCheck(ctx context.Context, check CheckFunc) *CheckResult {
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				errCh <- fmt.Errorf("panic: %v", e)
+			}
+		}()
+		errCh <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	cr := &CheckResult{Status: HealthStatusOk, Cost: time.Since(start).String()}
+	if err != nil {
+		cr.Status = HealthStatusFail
+		cr.Error = err.Error()
+	}
+	return cr
+}
+
+// RegisterLivenessCheck registers a named check reported by liveness endpoint, a check with same name is replaced.
+func (h *Httpd) RegisterLivenessCheck(name string, check CheckFunc) {
+	h.liveness.register(name, check)
+}
+
+// RegisterReadinessCheck registers a named check reported by readiness endpoint, a check with same name is replaced.
+func (h *Httpd) RegisterReadinessCheck(name string, check CheckFunc) {
+	h.readiness.register(name, check)
+}
+
+// shutdownCheck fails as soon as httpd or any sibling daemon starts stopping.
+func (h *Httpd) shutdownCheck(context.Context) error {
+	select {
+	case <-h.Stopping():
+		return ErrShuttingDown
+	default:
+	}
+
+	if h.Parent() == nil {
+		return nil
+	}
+	for _, r := range append(h.Parent().Children(), h.Parent()) {
+		select {
+		case <-r.Stopping():
+			return fmt.Errorf("%w: %s is stopping", ErrShuttingDown, r.Name())
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *Server) livenessHandler(w http.ResponseWriter, _ *http.Request) {
+	respHealth(runChecks(s.h.liveness.snapshot(), s.Cfg().HealthCheckTimeout), w)
+}
+
+func (s *Server) readinessHandler(w http.ResponseWriter, _ *http.Request) {
+	checks := s.h.readiness.snapshot()
+	checks[shutdownCheckName] = s.h.shutdownCheck
+	respHealth(runChecks(checks, s.Cfg().HealthCheckTimeout), w)
+}
+
+func respHealth(hr *HealthResult, w http.ResponseWriter) {
+	statusCode := http.StatusOK
+	if hr.Status != HealthStatusOk {
+		statusCode = http.StatusServiceUnavailable
+	}
+	httpu.RespJSON(statusCode, hr, w)
+}
+
+func RegisterLivenessCheck(name string, check CheckFunc) {
+	_h.RegisterLivenessCheck(name, check)
+}
+
+func RegisterReadinessCheck(name string, check CheckFunc) {
+	_h.RegisterReadinessCheck(name, check)
+}
@@ -0,0 +1,35 @@
+package httpd
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func http2Configured(cfg *Cfg) bool {
+	return cfg.EnableH2C || cfg.HTTP2MaxConcurrentStreams > 0 || cfg.HTTP2MaxReadFrameSize > 0
+}
+
+func newHTTP2Server(cfg *Cfg) *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams: cfg.HTTP2MaxConcurrentStreams,
+		MaxReadFrameSize:     cfg.HTTP2MaxReadFrameSize,
+		IdleTimeout:          cfg.IdleTimeout,
+	}
+}
+
+// configureHTTP2 applies http2 settings to srv, it must be called after srv.TLSConfig and srv.Handler are set.
+// Without tls, h2c is served along with http/1.1 on the same listener if enabled,
+// both prior knowledge and upgrade from http/1.1 are supported.
+func configureHTTP2(srv *http.Server, cfg *Cfg) error {
+	if !http2Configured(cfg) {
+		return nil
+	}
+
+	h2s := newHTTP2Server(cfg)
+	if srv.TLSConfig == nil && cfg.EnableH2C {
+		srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	}
+	return http2.ConfigureServer(srv, h2s)
+}
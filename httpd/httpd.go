@@ -3,8 +3,11 @@ package httpd
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"plugin"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/donkeywon/golib/boot"
@@ -16,49 +19,119 @@ import (
 
 const DaemonTypeHttpd boot.DaemonType = "httpd"
 
-type MiddlewareFunc func(http.Handler) http.Handler
-
-func init() {
-	_h.RegisterMiddleware(logAndRecoverMiddleware)
-}
-
-var _h = &Httpd{
-	Runner: runner.Create(string(DaemonTypeHttpd)),
-	mux:    http.NewServeMux(),
-}
+var _h = newHttpd()
 
 type Httpd struct {
 	runner.Runner
 	plugin.Plugin
 	*Cfg
 
-	s           *http.Server
-	mux         *http.ServeMux
-	middlewares []MiddlewareFunc
+	mu       sync.Mutex
+	reloadMu sync.Mutex
+	servers  map[string]*Server
+	running  []*Server
+
+	liveness  *healthChecks
+	readiness *healthChecks
 }
 
-func newHTTPServer(cfg *Cfg) *http.Server {
-	return &http.Server{
-		Addr:              cfg.Addr,
-		ReadTimeout:       cfg.ReadTimeout,
-		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
-		WriteTimeout:      cfg.WriteTimeout,
-		IdleTimeout:       cfg.IdleTimeout,
+func newHttpd() *Httpd {
+	h := &Httpd{
+		Runner:    runner.Create(string(DaemonTypeHttpd)),
+		servers:   make(map[string]*Server),
+		liveness:  newHealthChecks(),
+		readiness: newHealthChecks(),
 	}
+	h.servers[DefaultServerName] = newServer(h, DefaultServerName)
+	return h
 }
 
 func New() *Httpd {
 	return _h
 }
 
+func (h *Httpd) Init() error {
+	if _, exists := h.Cfg.Servers[DefaultServerName]; exists {
+		return errs.Errorf("server name %s is reserved", DefaultServerName)
+	}
+
+	err := h.GetServer(DefaultServerName).init(h.Cfg)
+	if err != nil {
+		return errs.Wrapf(err, "init server %s fail", DefaultServerName)
+	}
+	for name, cfg := range h.Cfg.Servers {
+		if cfg == nil {
+			return errs.Errorf("server %s has no cfg", name)
+		}
+		err = h.GetServer(name).init(cfg)
+		if err != nil {
+			return errs.Wrapf(err, "init server %s fail", name)
+		}
+	}
+	err = h.checkUnconfiguredServers()
+	if err != nil {
+		return err
+	}
+	return h.Runner.Init()
+}
+
 func (h *Httpd) Start() error {
-	h.s = newHTTPServer(h.Cfg)
-	h.setMux()
-	return h.s.ListenAndServe()
+	err := h.checkUnconfiguredServers()
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	for _, name := range h.serverNames() {
+		s := h.servers[name]
+		if s.Cfg() != nil {
+			h.running = append(h.running, s)
+		}
+	}
+	running := h.running
+	h.mu.Unlock()
+
+	errCh := make(chan error, len(running))
+	for _, s := range running {
+		go func(s *Server) {
+			errCh <- errs.Wrapf(s.start(), "server %s serve fail", s.name)
+		}(s)
+	}
+
+	for range running {
+		e := <-errCh
+		if e != nil && err == nil {
+			// one server broken, stop the others
+			err = e
+			go h.stopAll()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return http.ErrServerClosed
 }
 
 func (h *Httpd) Stop() error {
-	return h.s.Close()
+	return h.stopAll()
+}
+
+func (h *Httpd) stopAll() error {
+	h.mu.Lock()
+	running := h.running
+	h.mu.Unlock()
+
+	wg := sync.WaitGroup{}
+	errList := make([]error, len(running))
+	for i, s := range running {
+		wg.Add(1)
+		go func(i int, s *Server) {
+			defer wg.Done()
+			errList[i] = errs.Wrapf(s.stop(), "server %s stop fail", s.name)
+		}(i, s)
+	}
+	wg.Wait()
+	return errors.Join(errList...)
 }
 
 func (h *Httpd) Type() interface{} {
@@ -66,6 +139,8 @@ func (h *Httpd) Type() interface{} {
 }
 
 func (h *Httpd) GetCfg() interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.Cfg
 }
 
@@ -77,24 +152,54 @@ func (h *Httpd) AppendError(err ...error) {
 	}
 }
 
-func (h *Httpd) RegisterMiddleware(mf ...MiddlewareFunc) {
-	h.middlewares = append(h.middlewares, mf...)
+// GetServer returns the named server instance, it will be created if not exists.
+// Init and Start fail if handlers are registered on a server without cfg in Servers.
+func (h *Httpd) GetServer(name string) *Server {
+	if name == "" {
+		name = DefaultServerName
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, exists := h.servers[name]
+	if !exists {
+		s = newServer(h, name)
+		h.servers[name] = s
+	}
+	return s
 }
 
-func (h *Httpd) setMux() {
-	h.s.Handler = h.mux
+// checkUnconfiguredServers returns error if handlers are registered on a server without cfg in Servers,
+// they would never be served.
+func (h *Httpd) checkUnconfiguredServers() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, name := range h.serverNames() {
+		s := h.servers[name]
+		if s.Cfg() != nil {
+			continue
+		}
+		s.mu.Lock()
+		n := len(s.routes)
+		s.mu.Unlock()
+		if n > 0 {
+			return errs.Errorf("server %s has %d handlers registered but no cfg in servers", name, n)
+		}
+	}
+	return nil
 }
 
-func (h *Httpd) buildHandlerChain(next http.Handler) http.Handler {
-	handler := next
-	for i := len(h.middlewares) - 1; i >= 0; i-- {
-		handler = h.middlewares[i](handler)
+func (h *Httpd) serverNames() []string {
+	names := make([]string, 0, len(h.servers))
+	for name := range h.servers {
+		names = append(names, name)
 	}
-	return handler
+	sort.Strings(names)
+	return names
 }
 
-func logFields(r *http.Request, w *recordResponseWriter, startTs int64, endTs int64) []interface{} {
-	return []interface{}{
+func logFields(r *http.Request, w *recordResponseWriter, startTs int64, endTs int64, extra ...interface{}) []interface{} {
+	return append([]interface{}{
 		"status", w.statusCode,
 		"uri", r.RequestURI,
 		"remote", r.RemoteAddr,
@@ -102,50 +207,111 @@ func logFields(r *http.Request, w *recordResponseWriter, startTs int64, endTs in
 		"req_body_size", r.ContentLength,
 		"resp_body_size", w.nw,
 		"cost", fmt.Sprintf("%.6fms", float64(endTs-startTs)/float64(time.Millisecond)),
-	}
+	}, extra...)
+}
+
+func GetServer(name string) *Server {
+	return _h.GetServer(name)
 }
 
 // RegisterMiddleware must called before Handle func below
 func RegisterMiddleware(mf ...MiddlewareFunc) {
-	_h.RegisterMiddleware(mf...)
+	_h.GetServer(DefaultServerName).RegisterMiddleware(mf...)
+}
+
+func Auth() MiddlewareFunc {
+	return _h.GetServer(DefaultServerName).Auth()
+}
+
+func Group(prefix string, mf ...MiddlewareFunc) *Router {
+	return _h.GetServer(DefaultServerName).Group(prefix, mf...)
 }
 
 func Handle(pattern string, handler http.Handler) {
-	_h.mux.Handle(pattern, _h.buildHandlerChain(handler))
+	_h.GetServer(DefaultServerName).Handle(pattern, handler)
 }
 
 func HandleFunc(pattern string, handler http.HandlerFunc) {
-	_h.mux.HandleFunc(pattern, _h.buildHandlerChain(handler).ServeHTTP)
+	_h.GetServer(DefaultServerName).HandleFunc(pattern, handler)
 }
 
 func HandleRaw(pattern string, handler RawHandler) {
-	_h.mux.Handle(pattern, _h.buildHandlerChain(handler))
+	_h.GetServer(DefaultServerName).HandleRaw(pattern, handler)
 }
 
 func HandleAPI(pattern string, handler APIHandler) {
-	_h.mux.Handle(pattern, _h.buildHandlerChain(handler))
+	_h.GetServer(DefaultServerName).HandleAPI(pattern, handler)
 }
 
 func HandleREST(pattern string, handler RESTHandler) {
-	_h.mux.Handle(pattern, _h.buildHandlerChain(handler))
+	_h.GetServer(DefaultServerName).HandleREST(pattern, handler)
+}
+
+func HandleSSE(pattern string, handler SSEHandler) {
+	_h.GetServer(DefaultServerName).HandleSSE(pattern, handler)
 }
 
-func logAndRecoverMiddleware(next http.Handler) http.Handler {
+func HandleWS(pattern string, handler WSHandler) {
+	_h.GetServer(DefaultServerName).HandleWS(pattern, handler)
+}
+
+func HandleProxy(pattern string, targets []string, opts *ProxyOptions) error {
+	return _h.GetServer(DefaultServerName).HandleProxy(pattern, targets, opts)
+}
+
+func HandleStatic(pattern string, fsys fs.FS, opts *StaticOptions) {
+	_h.GetServer(DefaultServerName).HandleStatic(pattern, fsys, opts)
+}
+
+func Unhandle(pattern string) bool {
+	return _h.GetServer(DefaultServerName).Unhandle(pattern)
+}
+
+func (s *Server) logAndRecoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w = newWriteOnceRecordResponseWriter(w)
 
+		reqID := reqIDFromHeader(r.Header.Get(HeaderRequestID))
+		w.Header().Set(HeaderRequestID, reqID)
+		l := &reqLogger{l: s.h, kvs: []any{"server", s.name, "request_id", reqID}}
+		ctx, extraFields := withLogFields(withRequestID(r.Context(), reqID, l))
+		r = r.WithContext(ctx)
+
+		observe := _metrics.begin(s.name, routeFromCtx(r.Context()).pattern, metricsMethod(r.Method))
+		var body *countingBody
+		if observe != nil && r.ContentLength < 0 && r.Body != nil {
+			body = &countingBody{ReadCloser: r.Body}
+			r.Body = body
+		}
+
 		start := time.Now().UnixNano()
 		defer func() {
 			end := time.Now().UnixNano()
+			rw := w.(*recordResponseWriter)
+			if observe != nil {
+				reqSize := r.ContentLength
+				if body != nil {
+					reqSize = body.n.Load()
+				}
+				defer func() { observe(rw.statusCode, reqSize, rw.nw, time.Duration(end-start)) }()
+			}
 
 			e := recover()
 			if e != nil {
 				err := errs.PanicToErr(e)
-				_h.Error("handle req fail, panic occurred", err, logFields(r, w.(*recordResponseWriter), start, end)...)
-				errStr := errs.ErrToStackString(err)
-				httpu.RespRaw(http.StatusInternalServerError, conv.String2Bytes(errStr), w)
-			} else {
-				_h.Info("handle req", logFields(r, w.(*recordResponseWriter), start, end)...)
+				l.Error("handle req fail, panic occurred", err, logFields(r, rw, start, end, extraFields.fields()...)...)
+				httpu.RespRaw(http.StatusInternalServerError, conv.String2Bytes(panicMsg(r, err)), w)
+				return
+			}
+			// status without body, e.g. 204 or 304, has not been written yet
+			rw.writeHeader()
+
+			switch accessLogLevelOf(s.Cfg(), r, rw.statusCode, time.Duration(end-start)) {
+			case accessLogWarn:
+				l.Warn("handle req slow", append(logFields(r, rw, start, end, extraFields.fields()...), "req_headers", redactHeaders(r.Header))...)
+			case accessLogInfo:
+				l.Info("handle req", logFields(r, rw, start, end, extraFields.fields()...)...)
+			case accessLogSkip:
 			}
 		}()
 
@@ -0,0 +1,664 @@
+package httpd
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/donkeywon/golib/runner"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// startHttpd starts an Httpd with cfg and handlers registered by register, and returns the base url of
+// the default server. cfg.Addr is set to a free loopback address if empty.
+func startHttpd(t *testing.T, cfg *Cfg, register func(h *Httpd)) string {
+	if cfg.Addr == "" {
+		cfg.Addr = freeAddr(t)
+	}
+	h := newHttpd()
+	h.Cfg = cfg
+	register(h)
+	require.NoError(t, runner.Init(h))
+	go runner.Start(h)
+	t.Cleanup(func() { runner.Stop(h) })
+
+	network, addr, host := "tcp", cfg.Addr, cfg.Addr
+	if path, ok := strings.CutPrefix(cfg.Addr, addrSchemeUnix); ok {
+		network, addr, host = "unix", path, "unix"
+	}
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}, 3*time.Second, 10*time.Millisecond)
+
+	if tlsEnabled(cfg) {
+		return "https://" + host
+	}
+	return "http://" + host
+}
+
+// newH2CClient returns a client speaking http/2 with prior knowledge over cleartext.
+func newH2CClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func TestH2CAndHTTP1OnSameListener(t *testing.T) {
+	cfg := NewCfg()
+	cfg.EnableH2C = true
+	cfg.HTTP2MaxConcurrentStreams = 10
+
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /proto", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		})
+	})
+
+	h2cClient := newH2CClient()
+
+	for _, c := range []struct {
+		client *http.Client
+		proto  string
+	}{
+		{client: &http.Client{Transport: &http.Transport{}}, proto: "HTTP/1.1"},
+		{client: h2cClient, proto: "HTTP/2.0"},
+	} {
+		resp, err := c.client.Get(base + "/proto")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, c.proto, resp.Proto)
+		require.Equal(t, c.proto, string(body))
+	}
+}
+
+func TestH2CDisabled(t *testing.T) {
+	cfg := NewCfg()
+
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /proto", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		})
+	})
+
+	h2cClient := newH2CClient()
+	_, err := h2cClient.Get(base + "/proto")
+	require.Error(t, err)
+}
+
+func TestStopDrainsInFlight(t *testing.T) {
+	cfg := NewCfg()
+	cfg.WriteTimeout = 5 * time.Second
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var h *Httpd
+	base := startHttpd(t, cfg, func(hd *Httpd) {
+		h = hd
+		h.GetServer(DefaultServerName).HandleFunc("GET /slow", func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			<-release
+			_, _ = w.Write([]byte("done"))
+		})
+	})
+
+	bodyCh := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			bodyCh <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		bodyCh <- string(body)
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- h.Stop() }()
+
+	// new connections are refused while draining
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", cfg.Addr)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err != nil
+	}, 3*time.Second, 10*time.Millisecond)
+	select {
+	case <-stopped:
+		t.Fatal("stop returned before in-flight request finished")
+	default:
+	}
+
+	close(release)
+	require.Equal(t, "done", <-bodyCh)
+	require.NoError(t, <-stopped)
+}
+
+func TestStopTimeoutAbortsConns(t *testing.T) {
+	cfg := NewCfg()
+	cfg.WriteTimeout = 5 * time.Second
+	cfg.IdleTimeout = time.Minute
+	cfg.ShutdownTimeout = 100 * time.Millisecond
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	var h *Httpd
+	base := startHttpd(t, cfg, func(hd *Httpd) {
+		h = hd
+		h.GetServer(DefaultServerName).HandleFunc("GET /slow", func(http.ResponseWriter, *http.Request) {
+			close(started)
+			<-release
+		})
+	})
+
+	// idle keep-alive connection is not counted as aborted
+	idleClient := &http.Client{Transport: &http.Transport{}}
+	resp, err := idleClient.Get(base + "/healthz")
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		errCh <- err
+	}()
+	<-started
+
+	s := h.GetServer(DefaultServerName)
+	s.srvMu.Lock()
+	ct := s.connTracker
+	s.srvMu.Unlock()
+	require.Equal(t, 1, ct.activeCount())
+
+	begin := time.Now()
+	require.NoError(t, h.Stop())
+	require.Less(t, time.Since(begin), 3*time.Second)
+	require.Error(t, <-errCh)
+}
+
+func TestReloadAddrDrainsInFlight(t *testing.T) {
+	// in-flight requests are drained even if ShutdownTimeout is not positive
+	for _, shutdownTimeout := range []time.Duration{5 * time.Second, 0} {
+		cfg := NewCfg()
+		cfg.WriteTimeout = 5 * time.Second
+		cfg.ShutdownTimeout = shutdownTimeout
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var h *Httpd
+		base := startHttpd(t, cfg, func(hd *Httpd) {
+			h = hd
+			h.GetServer(DefaultServerName).HandleFunc("GET /slow", func(w http.ResponseWriter, _ *http.Request) {
+				close(started)
+				<-release
+				_, _ = w.Write([]byte("done"))
+			})
+		})
+
+		type result struct {
+			body string
+			err  error
+		}
+		resCh := make(chan result, 1)
+		go func() {
+			resp, err := http.Get(base + "/slow")
+			if err != nil {
+				resCh <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			resCh <- result{body: string(body), err: err}
+		}()
+		<-started
+
+		newCfg := NewCfg()
+		newCfg.Addr = freeAddr(t)
+		newCfg.WriteTimeout = 5 * time.Second
+		newCfg.ShutdownTimeout = shutdownTimeout
+		// reload does not wait for in-flight requests
+		require.NoError(t, h.Reload(newCfg))
+
+		_, err := net.Dial("tcp", cfg.Addr)
+		require.Error(t, err)
+		conn, err := net.Dial("tcp", newCfg.Addr)
+		require.NoError(t, err)
+		_ = conn.Close()
+
+		close(release)
+		res := <-resCh
+		require.NoError(t, res.err)
+		require.Equal(t, "done", res.body)
+	}
+}
+
+func TestStopClosesDrainingServer(t *testing.T) {
+	cfg := NewCfg()
+	cfg.WriteTimeout = 5 * time.Second
+	cfg.ShutdownTimeout = 0
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	var h *Httpd
+	base := startHttpd(t, cfg, func(hd *Httpd) {
+		h = hd
+		h.GetServer(DefaultServerName).HandleFunc("GET /slow", func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			<-release
+		})
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		errCh <- err
+	}()
+	<-started
+
+	newCfg := NewCfg()
+	newCfg.Addr = cfg.Addr
+	newCfg.ShutdownTimeout = 0
+	require.NoError(t, h.Reload(newCfg))
+
+	// the previous http server waits for the request without timeout until stopped
+	require.NoError(t, h.Stop())
+	select {
+	case err := <-errCh:
+		require.Error(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("request of draining server not closed by stop")
+	}
+}
+
+func TestReloadFailChangesNoServer(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer occupied.Close()
+
+	cfg := NewCfg()
+	statusCfg := NewCfg()
+	statusCfg.Addr = freeAddr(t)
+	cfg.Servers = map[string]*Cfg{"status": statusCfg}
+	var h *Httpd
+	startHttpd(t, cfg, func(hd *Httpd) { h = hd })
+
+	// status is reloaded after default and fails to bind
+	newCfg := NewCfg()
+	newCfg.Addr = freeAddr(t)
+	newStatusCfg := NewCfg()
+	newStatusCfg.Addr = occupied.Addr().String()
+	newCfg.Servers = map[string]*Cfg{"status": newStatusCfg}
+	require.Error(t, h.Reload(newCfg))
+
+	require.Same(t, cfg, h.GetCfg())
+	require.Same(t, cfg, h.GetServer(DefaultServerName).Cfg())
+	require.Same(t, statusCfg, h.GetServer("status").Cfg())
+	_, err = net.Dial("tcp", newCfg.Addr)
+	require.Error(t, err)
+	for _, addr := range []string{cfg.Addr, statusCfg.Addr} {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		_ = conn.Close()
+	}
+}
+
+func TestReloadFailKeepsBuiltinHandlers(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer occupied.Close()
+
+	cfg := NewCfg()
+	var h *Httpd
+	base := startHttpd(t, cfg, func(hd *Httpd) { h = hd })
+
+	newCfg := NewCfg()
+	newCfg.Addr = occupied.Addr().String()
+	newCfg.LivenessPath = "GET /livez"
+	newCfg.RoutesPath = "GET /routes"
+	require.Error(t, h.Reload(newCfg))
+
+	for path, status := range map[string]int{"/healthz": http.StatusOK, "/livez": http.StatusNotFound, "/routes": http.StatusNotFound} {
+		resp, err := http.Get(base + path)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, status, resp.StatusCode, path)
+	}
+
+	newCfg.Addr = cfg.Addr
+	require.NoError(t, h.Reload(newCfg))
+	for path, status := range map[string]int{"/healthz": http.StatusNotFound, "/livez": http.StatusOK, "/routes": http.StatusOK} {
+		resp, err := http.Get(base + path)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, status, resp.StatusCode, path)
+	}
+}
+
+func TestHandleAndUnhandleWhileServing(t *testing.T) {
+	cfg := NewCfg()
+	var h *Httpd
+	base := startHttpd(t, cfg, func(hd *Httpd) { h = hd })
+	s := h.GetServer(DefaultServerName)
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(base + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, _ := get("/ping")
+	require.Equal(t, http.StatusNotFound, status)
+
+	s.HandleFunc("GET /ping", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	})
+	status, body := get("/ping")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "pong", body)
+
+	// conflicting pattern is rejected and serving routes are kept
+	require.Panics(t, func() { s.HandleFunc("GET /ping", func(http.ResponseWriter, *http.Request) {}) })
+	status, _ = get("/ping")
+	require.Equal(t, http.StatusOK, status)
+
+	require.True(t, s.Unhandle("GET /ping"))
+	require.False(t, s.Unhandle("GET /ping"))
+	status, _ = get("/ping")
+	require.Equal(t, http.StatusNotFound, status)
+
+	// proxy health checks are stopped when removed
+	upstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer upstream.Close()
+	require.NoError(t, s.HandleProxy("/api/", []string{upstream.URL}, &ProxyOptions{HealthCheckPath: "/healthz"}))
+	var p *proxyHandler
+	for _, rt := range s.routes {
+		if rt.pattern == "/api/" {
+			p = rt.handler.(*proxyHandler)
+		}
+	}
+	require.NotNil(t, p)
+	require.True(t, s.Unhandle("/api/"))
+	select {
+	case <-p.closeCh:
+	default:
+		t.Fatal("proxy handler not closed")
+	}
+}
+
+func TestNamedServers(t *testing.T) {
+	cfg := NewCfg()
+	adminCfg := NewCfg()
+	adminCfg.Addr = freeAddr(t)
+	cfg.Servers = map[string]*Cfg{"admin": adminCfg}
+
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		require.Same(t, h.GetServer(DefaultServerName), h.GetServer(""))
+		h.GetServer("").HandleFunc("GET /api", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("api"))
+		})
+		admin := h.GetServer("admin")
+		admin.RegisterMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Server", "admin")
+				next.ServeHTTP(w, r)
+			})
+		})
+		admin.HandleFunc("GET /admin", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("admin"))
+		})
+	})
+	adminURL := "http://" + adminCfg.Addr
+
+	tests := []struct {
+		url    string
+		status int
+		body   string
+		server string
+	}{
+		{url: base + "/api", status: http.StatusOK, body: "api"},
+		{url: base + "/admin", status: http.StatusNotFound},
+		{url: adminURL + "/admin", status: http.StatusOK, body: "admin", server: "admin"},
+		{url: adminURL + "/api", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(tt.url)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, tt.status, resp.StatusCode, tt.url)
+		require.Equal(t, tt.server, resp.Header.Get("X-Server"), tt.url)
+		if tt.body != "" {
+			require.Equal(t, tt.body, string(body), tt.url)
+		}
+	}
+}
+
+func TestUnconfiguredServerWithHandlers(t *testing.T) {
+	cfg := NewCfg()
+	cfg.Addr = freeAddr(t)
+
+	h := newHttpd()
+	h.Cfg = cfg
+	// server without handlers is ignored
+	h.GetServer("empty")
+	h.GetServer("admin").HandleFunc("GET /admin", func(http.ResponseWriter, *http.Request) {})
+	require.ErrorContains(t, h.Init(), "server admin")
+
+	// handlers registered after init
+	h = newHttpd()
+	h.Cfg = cfg
+	require.NoError(t, h.Init())
+	h.GetServer("admin").HandleFunc("GET /admin", func(http.ResponseWriter, *http.Request) {})
+	require.ErrorContains(t, h.Start(), "server admin")
+}
+
+func TestBuiltinHandlerConflict(t *testing.T) {
+	cfg := NewCfg()
+	cfg.Addr = freeAddr(t)
+
+	h := newHttpd()
+	h.Cfg = cfg
+	h.GetServer(DefaultServerName).HandleFunc(DefaultLivenessPath, func(http.ResponseWriter, *http.Request) {})
+	require.ErrorContains(t, h.Init(), DefaultLivenessPath)
+}
+
+func TestStopAfterStartFail(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer occupied.Close()
+
+	cfg := NewCfg()
+	cfg.Addr = freeAddr(t)
+	adminCfg := NewCfg()
+	adminCfg.Addr = occupied.Addr().String()
+	cfg.Servers = map[string]*Cfg{"admin": adminCfg}
+
+	h := newHttpd()
+	h.Cfg = cfg
+	require.NoError(t, runner.Init(h))
+
+	// admin fails to bind, default is stopped by Start
+	require.Error(t, h.Start())
+	require.NoError(t, h.Stop())
+	require.ErrorIs(t, h.Reload(cfg), ErrServerStopped)
+}
+
+func TestProxyRetryOnUnreachableTarget(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Internal", "1")
+		_, _ = w.Write([]byte(r.URL.Path + " " + r.Header.Get("X-Token")))
+	}))
+	defer upstream.Close()
+	unreachable := "http://" + freeAddr(t)
+
+	cfg := NewCfg()
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		require.NoError(t, s.HandleProxy("/api/", []string{unreachable, upstream.URL}, &ProxyOptions{
+			Retries:         1,
+			StripPrefix:     "/api",
+			RequestHeaders:  map[string]string{"X-Token": "token"},
+			ResponseHeaders: map[string]string{"X-Internal": ""},
+		}))
+		require.Error(t, s.HandleProxy("/invalid/", []string{"invalid"}, nil))
+	})
+
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(base + "/api/echo")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "/echo token", string(body))
+		require.Empty(t, resp.Header.Get("X-Internal"))
+	}
+
+	// requests with body are not retried
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(base+"/api/echo", "text/plain", strings.NewReader("body"))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+			return
+		}
+	}
+	t.Fatal("request with body should not be retried")
+}
+
+func TestProxyForwardedHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Forwarded-For") + "|" + r.Header.Get("X-Forwarded-Proto")))
+	}))
+	defer upstream.Close()
+
+	cfg := NewCfg()
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		require.NoError(t, s.HandleProxy("/untrusted/", []string{upstream.URL}, nil))
+		require.NoError(t, s.HandleProxy("/trusted/", []string{upstream.URL}, &ProxyOptions{TrustForwardedHeaders: true}))
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/untrusted/", want: "127.0.0.1|http"},
+		{path: "/trusted/", want: "10.0.0.1, 127.0.0.1|https"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, base+tt.path, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, tt.want, string(body), tt.path)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte("index")},
+		"app.js":     {Data: []byte("app"), ModTime: time.Now()},
+		"app.js.gz":  {Data: []byte("gzipped app")},
+		"assets/a":   {Data: []byte("a")},
+	}
+
+	cfg := NewCfg()
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleStatic("/ui/", fsys, &StaticOptions{
+			StripPrefix:   "/ui",
+			SPAFallback:   true,
+			Precompressed: true,
+		})
+	})
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	get := func(path string, header http.Header) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, base+path, nil)
+		require.NoError(t, err)
+		req.Header = header
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	resp, body := get("/ui/app.js", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "app", body)
+	require.NotEmpty(t, resp.Header.Get("Last-Modified"))
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	resp, _ = get("/ui/app.js", http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body = get("/ui/app.js", http.Header{"Accept-Encoding": {"br, gzip"}})
+	require.Equal(t, "gzipped app", body)
+	require.Equal(t, EncodingGzip, resp.Header.Get("Content-Encoding"))
+	require.Contains(t, resp.Header.Get("Content-Type"), "javascript")
+	require.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	resp, body = get("/ui/settings/users", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "index", body)
+	require.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	resp, _ = get("/ui/missing.js", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// directory listing is disabled by default, SPA fallback serves index instead
+	resp, body = get("/ui/assets/", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "index", body)
+}
@@ -0,0 +1,209 @@
+package httpd
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	RateLimitKeyIP   = "ip"
+	RateLimitKeyAuth = "auth"
+	// RateLimitKeyHeaderPrefix followed by a header name identifies clients by the header value, e.g. header:X-User-ID,
+	// clients without the header are identified by ip.
+	RateLimitKeyHeaderPrefix = "header:"
+
+	headerRetryAfter = "Retry-After"
+
+	rateLimiterIdleTimeout   = 3 * time.Minute
+	rateLimiterCleanInterval = time.Minute
+	// rateLimiterMaxClients bounds memory of limiters, clients beyond it share one limiter until idle ones are cleaned
+	rateLimiterMaxClients = 100000
+)
+
+type clientLimiter struct {
+	lim      *rate.Limiter
+	lastSeen atomic.Int64
+}
+
+type admission struct {
+	rateLimit   rate.Limit
+	burst       int
+	keyByAuth   bool
+	keyHeader   string
+	maxInFlight int64
+	maxClients  int
+
+	inFlight atomic.Int64
+	mu       sync.Mutex
+	limiters map[string]*clientLimiter
+	overflow *rate.Limiter
+}
+
+func newAdmission(cfg *Cfg) *admission {
+	a := &admission{
+		rateLimit:   rate.Limit(cfg.RateLimit),
+		burst:       cfg.RateLimitBurst,
+		keyByAuth:   cfg.RateLimitKey == RateLimitKeyAuth,
+		maxInFlight: int64(cfg.MaxInFlight),
+		maxClients:  rateLimiterMaxClients,
+		limiters:    make(map[string]*clientLimiter),
+	}
+	if name, ok := strings.CutPrefix(cfg.RateLimitKey, RateLimitKeyHeaderPrefix); ok {
+		a.keyHeader = http.CanonicalHeaderKey(name)
+	}
+	if a.burst <= 0 {
+		a.burst = max(1, int(math.Ceil(cfg.RateLimit)))
+	}
+	a.overflow = rate.NewLimiter(a.rateLimit, a.burst)
+	return a
+}
+
+func (a *admission) rateLimitEnabled() bool {
+	return a.rateLimit > 0
+}
+
+func (a *admission) limiter(key string) *rate.Limiter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cl, exists := a.limiters[key]
+	if !exists {
+		if len(a.limiters) >= a.maxClients {
+			a.cleanIdle()
+			if len(a.limiters) >= a.maxClients {
+				return a.overflow
+			}
+		}
+		cl = &clientLimiter{lim: rate.NewLimiter(a.rateLimit, a.burst)}
+		a.limiters[key] = cl
+	}
+	cl.lastSeen.Store(time.Now().UnixNano())
+	return cl.lim
+}
+
+// cleanLoop removes limiters of clients not seen for a while, to keep memory bounded.
+func (a *admission) cleanLoop(stopCh <-chan struct{}) {
+	t := time.NewTicker(rateLimiterCleanInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-t.C:
+			a.mu.Lock()
+			a.cleanIdle()
+			a.mu.Unlock()
+		}
+	}
+}
+
+// cleanIdle must be called with mu held.
+func (a *admission) cleanIdle() {
+	deadline := time.Now().Add(-rateLimiterIdleTimeout).UnixNano()
+	for key, cl := range a.limiters {
+		if cl.lastSeen.Load() < deadline {
+			delete(a.limiters, key)
+		}
+	}
+}
+
+// allow consumes a token of client key, it responds 429 and returns false if rate limited.
+func (a *admission) allow(w http.ResponseWriter, r *http.Request, key string) bool {
+	res := a.limiter(key).Reserve()
+	if delay := res.Delay(); delay > 0 {
+		res.Cancel()
+		AddLogFields(r.Context(), "rejected", "rate_limited", "rate_limit_key", key)
+		w.Header().Set(headerRetryAfter, strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// pendingRateLimit is put in request context when clients are identified by auth, admission runs before auth
+// so the request is limited later by verified identity in Server.Auth, or by ip before handler if not authenticated.
+type pendingRateLimit struct {
+	a    *admission
+	done bool
+}
+
+// allowPending applies the pending rate limit of the request by id, by ip if id is empty,
+// it returns true if no pending rate limit.
+func allowPending(w http.ResponseWriter, r *http.Request, id string) bool {
+	p, ok := r.Context().Value(ctxKeyPendingRateLimit).(*pendingRateLimit)
+	if !ok || p.done {
+		return true
+	}
+	p.done = true
+	if id == "" {
+		id = remoteIP(r)
+	}
+	return p.a.allow(w, r, id)
+}
+
+// rateLimitPendingMiddleware is the innermost middleware of each route, it limits requests not authenticated by Server.Auth.
+func rateLimitPendingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowPending(w, r, "") {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (s *Server) admissionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := s.st.Load().admission
+		if a == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if a.rateLimitEnabled() {
+			if a.keyByAuth {
+				r = r.WithContext(context.WithValue(r.Context(), ctxKeyPendingRateLimit, &pendingRateLimit{a: a}))
+			} else if !a.allow(w, r, a.clientKey(r)) {
+				return
+			}
+		}
+
+		if a.maxInFlight > 0 {
+			if a.inFlight.Add(1) > a.maxInFlight {
+				a.inFlight.Add(-1)
+				AddLogFields(r.Context(), "rejected", "max_in_flight")
+				w.Header().Set(headerRetryAfter, "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			defer a.inFlight.Add(-1)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey returns the key of client for rate limit by header or ip.
+func (a *admission) clientKey(r *http.Request) string {
+	if a.keyHeader != "" {
+		if v := r.Header.Get(a.keyHeader); v != "" {
+			return v
+		}
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,168 @@
+package httpd
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/donkeywon/golib/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokensFile, []byte("token-a\ntoken-b\n"), 0o600))
+
+	ipCfg := NewCfg()
+	ipCfg.RateLimit = 0.001
+	ipCfg.RateLimitBurst = 1
+	ipCfg.RateLimitKey = RateLimitKeyIP
+	ipCfg.LivenessPath = ""
+	ipCfg.ReadinessPath = ""
+	authCfg := NewCfg()
+	authCfg.Addr = freeAddr(t)
+	authCfg.RateLimit = 0.001
+	authCfg.RateLimitBurst = 1
+	authCfg.RateLimitKey = RateLimitKeyAuth
+	authCfg.AuthBearerTokensFile = tokensFile
+	authCfg.LivenessPath = ""
+	authCfg.ReadinessPath = ""
+	headerCfg := NewCfg()
+	headerCfg.Addr = freeAddr(t)
+	headerCfg.RateLimit = 0.001
+	headerCfg.RateLimitBurst = 1
+	headerCfg.RateLimitKey = RateLimitKeyHeaderPrefix + "x-user-id"
+	headerCfg.LivenessPath = ""
+	headerCfg.ReadinessPath = ""
+	ipCfg.Servers = map[string]*Cfg{"auth": authCfg, "header": headerCfg}
+
+	ok := func(w http.ResponseWriter, _ *http.Request) {}
+	base := startHttpd(t, ipCfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /public", ok)
+		s := h.GetServer("auth")
+		s.HandleFunc("GET /public", ok)
+		s.Group("", s.Auth()).HandleFunc("GET /private", ok)
+		h.GetServer("header").HandleFunc("GET /public", ok)
+	})
+
+	authURL := "http://" + authCfg.Addr
+	var lastRetryAfter string
+	get := func(base string, path string, token string) int {
+		req, err := http.NewRequest(http.MethodGet, base+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set(HeaderAuthorization, "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		lastRetryAfter = resp.Header.Get(headerRetryAfter)
+		return resp.StatusCode
+	}
+
+	// identity claimed in headers is ignored when limited by ip
+	require.Equal(t, http.StatusOK, get(base, "/public", "token-a"))
+	require.Equal(t, http.StatusTooManyRequests, get(base, "/public", "token-b"))
+	require.Equal(t, "1000", lastRetryAfter)
+
+	// each verified identity has its own limit
+	require.Equal(t, http.StatusOK, get(authURL, "/private", "token-a"))
+	require.Equal(t, http.StatusTooManyRequests, get(authURL, "/private", "token-a"))
+	require.Equal(t, http.StatusOK, get(authURL, "/private", "token-b"))
+
+	// unverified identities share the limit of ip, including requests to routes without auth
+	require.Equal(t, http.StatusUnauthorized, get(authURL, "/private", "forged-1"))
+	require.Equal(t, http.StatusTooManyRequests, get(authURL, "/private", "forged-2"))
+	require.Equal(t, http.StatusTooManyRequests, get(authURL, "/public", "token-a"))
+
+	// each header value has its own limit, requests without the header are limited by ip
+	getByHeader := func(user string) int {
+		req, err := http.NewRequest(http.MethodGet, "http://"+headerCfg.Addr+"/public", nil)
+		require.NoError(t, err)
+		if user != "" {
+			req.Header.Set("X-User-ID", user)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, getByHeader("user-a"))
+	require.Equal(t, http.StatusTooManyRequests, getByHeader("user-a"))
+	require.Equal(t, http.StatusOK, getByHeader("user-b"))
+	require.Equal(t, http.StatusOK, getByHeader(""))
+	require.Equal(t, http.StatusTooManyRequests, getByHeader(""))
+}
+
+func TestRateLimiterMaxClients(t *testing.T) {
+	cfg := NewCfg()
+	cfg.RateLimit = 1
+	a := newAdmission(cfg)
+	a.maxClients = 2
+
+	lim1 := a.limiter("1")
+	require.Same(t, lim1, a.limiter("1"))
+	require.NotSame(t, lim1, a.limiter("2"))
+	require.Same(t, a.overflow, a.limiter("3"))
+	require.Same(t, a.overflow, a.limiter("4"))
+	require.Len(t, a.limiters, 2)
+}
+
+func TestRateLimitKeyValidate(t *testing.T) {
+	cfg := NewCfg()
+	cfg.Addr = "127.0.0.1:8080"
+	for _, key := range []string{"", RateLimitKeyIP, RateLimitKeyAuth, "header:X-User"} {
+		cfg.RateLimitKey = key
+		require.NoError(t, util.V.Struct(cfg), key)
+		require.NoError(t, cfg.validate(), key)
+	}
+	for _, key := range []string{"IP", "Header:X-User", "user"} {
+		cfg.RateLimitKey = key
+		require.Error(t, util.V.Struct(cfg), key)
+	}
+	cfg.RateLimitKey = RateLimitKeyHeaderPrefix
+	require.Error(t, cfg.validate())
+}
+
+func TestMaxInFlight(t *testing.T) {
+	cfg := NewCfg()
+	cfg.MaxInFlight = 1
+	cfg.WriteTimeout = 5 * time.Second
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /slow", func(http.ResponseWriter, *http.Request) {
+			close(started)
+			<-release
+		})
+	})
+
+	done := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			done <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-started
+
+	resp, err := http.Get(base + "/healthz")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get(headerRetryAfter))
+
+	close(release)
+	require.Equal(t, http.StatusOK, <-done)
+	// slot is released after the request finished
+	resp, err = http.Get(base + "/healthz")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
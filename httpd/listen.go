@@ -0,0 +1,220 @@
+package httpd
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+const (
+	addrSchemeUnix    = "unix://"
+	addrSchemeSystemd = "systemd://"
+
+	systemdListenFdsStart = 3
+)
+
+var (
+	systemdFdsOnce sync.Once
+	systemdFdsMu   sync.Mutex
+	systemdFds     []*systemdFd
+	systemdFdsErr  error
+)
+
+// systemdFd is a file descriptor passed by systemd, it is kept open so it can be listened on again after closed,
+// e.g. Httpd started again in the same process, but only by one listener at a time.
+type systemdFd struct {
+	fd      int
+	name    string
+	file    *os.File
+	claimed bool
+}
+
+// systemdListener returns its fd to the pool when closed.
+type systemdListener struct {
+	net.Listener
+	sf        *systemdFd
+	closeOnce sync.Once
+}
+
+func (l *systemdListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() {
+		systemdFdsMu.Lock()
+		l.sf.claimed = false
+		systemdFdsMu.Unlock()
+	})
+	return err
+}
+
+func listenAddrs(cfg *Cfg) []string {
+	var addrs []string
+	if cfg.Addr != "" {
+		addrs = append(addrs, cfg.Addr)
+	}
+	for _, addr := range cfg.Addrs {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func listen(cfg *Cfg, addr string) ([]net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, addrSchemeUnix):
+		l, err := listenUnix(cfg, strings.TrimPrefix(addr, addrSchemeUnix))
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	case strings.HasPrefix(addr, addrSchemeSystemd):
+		return listenSystemd(strings.TrimPrefix(addr, addrSchemeSystemd))
+	default:
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+}
+
+func listenUnix(cfg *Cfg, path string) (net.Listener, error) {
+	fi, err := os.Lstat(path)
+	if err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errs.Errorf("path exists and is not a socket: %s", path)
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return nil, errs.Wrapf(err, "remove stale socket fail: %s", path)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = chmodChownSocket(cfg, path)
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func chmodChownSocket(cfg *Cfg, path string) error {
+	if cfg.UnixSocketMode != "" {
+		mode, err := strconv.ParseUint(cfg.UnixSocketMode, 8, 32)
+		if err != nil {
+			return errs.Wrapf(err, "invalid unix socket mode: %s", cfg.UnixSocketMode)
+		}
+		err = os.Chmod(path, os.FileMode(mode))
+		if err != nil {
+			return errs.Wrapf(err, "chmod unix socket fail: %s", path)
+		}
+	}
+
+	if cfg.UnixSocketOwner == "" && cfg.UnixSocketGroup == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if cfg.UnixSocketOwner != "" {
+		u, err := user.Lookup(cfg.UnixSocketOwner)
+		if err != nil {
+			return errs.Wrapf(err, "lookup unix socket owner fail: %s", cfg.UnixSocketOwner)
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+	}
+	if cfg.UnixSocketGroup != "" {
+		g, err := user.LookupGroup(cfg.UnixSocketGroup)
+		if err != nil {
+			return errs.Wrapf(err, "lookup unix socket group fail: %s", cfg.UnixSocketGroup)
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+	err := os.Lchown(path, uid, gid)
+	if err != nil {
+		return errs.Wrapf(err, "chown unix socket fail: %s", path)
+	}
+	return nil
+}
+
+// listenSystemd returns listeners passed by systemd socket activation,
+// name is matched against FileDescriptorName= of the socket unit, empty name means all.
+// It fails if any of the fds is in use by another listener, e.g. listened by another server.
+func listenSystemd(name string) ([]net.Listener, error) {
+	systemdFdsOnce.Do(func() {
+		systemdFds, systemdFdsErr = loadSystemdFds()
+	})
+	if systemdFdsErr != nil {
+		return nil, systemdFdsErr
+	}
+
+	systemdFdsMu.Lock()
+	defer systemdFdsMu.Unlock()
+
+	var matched []*systemdFd
+	for _, sf := range systemdFds {
+		if name != "" && sf.name != name {
+			continue
+		}
+		if sf.claimed {
+			return nil, errs.Errorf("systemd fd %d named %s is already in use", sf.fd, sf.name)
+		}
+		matched = append(matched, sf)
+	}
+	if len(matched) == 0 {
+		return nil, errs.Errorf("no systemd socket activated listener found, name: %s", name)
+	}
+
+	ls := make([]net.Listener, 0, len(matched))
+	for _, sf := range matched {
+		// the fd is duplicated, closing the listener does not close it
+		l, err := net.FileListener(sf.file)
+		if err != nil {
+			for _, l := range ls {
+				_ = l.Close()
+			}
+			return nil, errs.Wrapf(err, "create listener from systemd fd %d fail", sf.fd)
+		}
+		ls = append(ls, l)
+	}
+
+	listeners := make([]net.Listener, 0, len(ls))
+	for i, l := range ls {
+		matched[i].claimed = true
+		listeners = append(listeners, &systemdListener{Listener: l, sf: matched[i]})
+	}
+	return listeners, nil
+}
+
+func loadSystemdFds() ([]*systemdFd, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errs.New("process is not socket activated by systemd")
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, errs.New("no file descriptor passed by systemd")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	fds := make([]*systemdFd, 0, nfds)
+	for i := 0; i < nfds; i++ {
+		fd := systemdListenFdsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		fds = append(fds, &systemdFd{fd: fd, name: name, file: os.NewFile(uintptr(fd), name)})
+	}
+	return fds, nil
+}
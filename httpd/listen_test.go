@@ -0,0 +1,167 @@
+package httpd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSystemdFds replaces fds passed by systemd with listeners on loopback named by names.
+func fakeSystemdFds(t *testing.T, names ...string) map[string]string {
+	systemdFdsOnce.Do(func() {})
+	addrs := make(map[string]string, len(names))
+	var fds []*systemdFd
+	for _, name := range names {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		f, err := l.(*net.TCPListener).File()
+		require.NoError(t, err)
+		_ = l.Close()
+		addrs[name] = l.Addr().String()
+		fds = append(fds, &systemdFd{fd: int(f.Fd()), name: name, file: f})
+	}
+
+	systemdFdsMu.Lock()
+	old, oldErr := systemdFds, systemdFdsErr
+	systemdFds, systemdFdsErr = fds, nil
+	systemdFdsMu.Unlock()
+	t.Cleanup(func() {
+		systemdFdsMu.Lock()
+		systemdFds, systemdFdsErr = old, oldErr
+		systemdFdsMu.Unlock()
+		for _, sf := range fds {
+			_ = sf.file.Close()
+		}
+	})
+	return addrs
+}
+
+func TestListenSystemdOwnership(t *testing.T) {
+	fakeSystemdFds(t, "http", "admin")
+
+	ls, err := listenSystemd("http")
+	require.NoError(t, err)
+	require.Len(t, ls, 1)
+
+	// claimed fd can not be listened on twice
+	_, err = listenSystemd("http")
+	require.ErrorContains(t, err, "already in use")
+	_, err = listenSystemd("")
+	require.ErrorContains(t, err, "already in use")
+	_, err = listenSystemd("unknown")
+	require.Error(t, err)
+
+	// fd is returned when closed
+	require.NoError(t, ls[0].Close())
+	ls, err = listenSystemd("")
+	require.NoError(t, err)
+	require.Len(t, ls, 2)
+	for _, l := range ls {
+		require.NoError(t, l.Close())
+	}
+}
+
+func TestSystemdListenerAfterRestart(t *testing.T) {
+	addrs := fakeSystemdFds(t, "http")
+
+	cfg := NewCfg()
+	cfg.Addr = freeAddr(t)
+	cfg.Addrs = []string{addrSchemeSystemd + "http"}
+	statusCfg := NewCfg()
+	statusCfg.Addr = addrSchemeSystemd + "http"
+	cfg.Servers = map[string]*Cfg{"status": statusCfg}
+
+	// another server can not listen on the same fd
+	h := newHttpd()
+	h.Cfg = cfg
+	require.NoError(t, h.Init())
+	require.ErrorContains(t, h.Start(), "already in use")
+	require.NoError(t, h.Stop())
+
+	// fd can be listened on again by a new Httpd in the same process
+	cfg.Servers = nil
+	for i := 0; i < 2; i++ {
+		startHttpd(t, cfg, func(hd *Httpd) { h = hd })
+		resp, err := http.Get("http://" + addrs["http"] + "/healthz")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, h.Stop())
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "httpd.sock")
+	// stale socket left by previous process is removed
+	stale, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	u, err := user.Current()
+	require.NoError(t, err)
+	g, err := user.LookupGroupId(u.Gid)
+	require.NoError(t, err)
+
+	cfg := NewCfg()
+	cfg.Addr = addrSchemeUnix + sock
+	tcpAddr := freeAddr(t)
+	cfg.Addrs = []string{" ", tcpAddr}
+	cfg.UnixSocketMode = "0600"
+	cfg.UnixSocketOwner = u.Username
+	cfg.UnixSocketGroup = g.Name
+	base := startHttpd(t, cfg, func(*Httpd) {})
+
+	fi, err := os.Stat(sock)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+	for _, c := range []struct {
+		client *http.Client
+		url    string
+	}{
+		{client: client, url: base},
+		{client: http.DefaultClient, url: "http://" + tcpAddr},
+	} {
+		resp, err := c.client.Get(c.url + "/healthz")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestListenUnixInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	// regular file is never removed
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	_, err := listenUnix(NewCfg(), file)
+	require.ErrorContains(t, err, "not a socket")
+	require.FileExists(t, file)
+
+	for _, cfg := range []*Cfg{
+		{UnixSocketMode: "rw"},
+		{UnixSocketOwner: "no-such-user-for-test"},
+		{UnixSocketGroup: "no-such-group-for-test"},
+	} {
+		sock := filepath.Join(dir, "httpd.sock")
+		_, err = listenUnix(cfg, sock)
+		require.Error(t, err)
+		// socket is closed and removed on failure
+		require.NoFileExists(t, sock)
+	}
+}
@@ -0,0 +1,111 @@
+package httpd
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "httpd"
+
+var _metrics = newMetrics()
+
+type metrics struct {
+	enabled atomic.Bool
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	reqSize  *prometheus.HistogramVec
+	respSize *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	labels := []string{"server", "route", "method", "status"}
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 10)
+	return &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Total number of http requests.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of http requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_in_flight",
+			Help:      "Number of http requests being served.",
+		}, []string{"server", "route", "method"}),
+		reqSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_size_bytes",
+			Help:      "Size of http request bodies.",
+			Buckets:   sizeBuckets,
+		}, labels),
+		respSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "response_size_bytes",
+			Help:      "Size of http response bodies.",
+			Buckets:   sizeBuckets,
+		}, labels),
+	}
+}
+
+func (m *metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.latency, m.inFlight, m.reqSize, m.respSize}
+}
+
+func (m *metrics) begin(server string, route string, method string) func(statusCode int, reqSize int64, respSize int, cost time.Duration) {
+	if !m.enabled.Load() {
+		return nil
+	}
+
+	inFlight := m.inFlight.WithLabelValues(server, route, method)
+	inFlight.Inc()
+	return func(statusCode int, reqSize int64, respSize int, cost time.Duration) {
+		inFlight.Dec()
+		status := strconv.Itoa(statusCode)
+		m.requests.WithLabelValues(server, route, method, status).Inc()
+		m.latency.WithLabelValues(server, route, method, status).Observe(cost.Seconds())
+		m.reqSize.WithLabelValues(server, route, method, status).Observe(float64(max(reqSize, 0)))
+		m.respSize.WithLabelValues(server, route, method, status).Observe(float64(max(respSize, 0)))
+	}
+}
+
+// metricsMethod returns method as label value, non-standard methods are mapped to OTHER
+// so that clients can not create unlimited series by arbitrary methods.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// countingBody counts bytes read from body of unknown length, e.g. chunked or http/2 requests without Content-Length.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.n.Add(int64(n))
+	return n, err
+}
+
+// Collectors enables http server metrics and returns the collectors to be registered, used by promd.
+func Collectors() []prometheus.Collector {
+	_metrics.enabled.Store(true)
+	return _metrics.collectors()
+}
@@ -0,0 +1,109 @@
+package httpd
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	Collectors()
+
+	cfg := NewCfg()
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("/metrics-test", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+		})
+	})
+
+	do := func(method string, body io.Reader) {
+		req, err := http.NewRequest(method, base+"/metrics-test", body)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	metric := func(c prometheus.Collector, method string) *dto.Metric {
+		var m prometheus.Metric
+		switch v := c.(type) {
+		case *prometheus.CounterVec:
+			m = v.WithLabelValues(DefaultServerName, "/metrics-test", method, "200")
+		case *prometheus.HistogramVec:
+			m = v.WithLabelValues(DefaultServerName, "/metrics-test", method, "200").(prometheus.Metric)
+		}
+		pb := &dto.Metric{}
+		require.NoError(t, m.Write(pb))
+		return pb
+	}
+
+	// non-standard methods share one label value
+	do("FOO", nil)
+	do("BAR", nil)
+	require.Equal(t, 2.0, metric(_metrics.requests, "OTHER").GetCounter().GetValue())
+
+	// body without Content-Length is counted by bytes read
+	do(http.MethodPost, struct{ io.Reader }{strings.NewReader(strings.Repeat("a", 1000))})
+	do(http.MethodPost, strings.NewReader(strings.Repeat("a", 100)))
+	h := metric(_metrics.reqSize, http.MethodPost).GetHistogram()
+	require.Equal(t, uint64(2), h.GetSampleCount())
+	require.Equal(t, 1100.0, h.GetSampleSum())
+}
+
+func TestMetricsRouteLabels(t *testing.T) {
+	Collectors()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	base := startHttpd(t, NewCfg(), func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		s.HandleFunc("GET /metrics-users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.PathValue("id")))
+		})
+		s.HandleFunc("GET /metrics-slow", func(http.ResponseWriter, *http.Request) {
+			close(started)
+			<-release
+		})
+	})
+
+	// requests of the same route share series of route pattern
+	for _, id := range []string{"1", "22"} {
+		resp, err := http.Get(base + "/metrics-users/" + id)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	labels := []string{DefaultServerName, "GET /metrics-users/{id}", http.MethodGet, "200"}
+	pb := &dto.Metric{}
+	require.NoError(t, _metrics.requests.WithLabelValues(labels...).Write(pb))
+	require.Equal(t, 2.0, pb.GetCounter().GetValue())
+	pb = &dto.Metric{}
+	require.NoError(t, _metrics.respSize.WithLabelValues(labels...).(prometheus.Metric).Write(pb))
+	require.Equal(t, 3.0, pb.GetHistogram().GetSampleSum())
+	pb = &dto.Metric{}
+	require.NoError(t, _metrics.latency.WithLabelValues(labels...).(prometheus.Metric).Write(pb))
+	require.Equal(t, uint64(2), pb.GetHistogram().GetSampleCount())
+
+	inFlight := func() float64 {
+		pb := &dto.Metric{}
+		require.NoError(t, _metrics.inFlight.WithLabelValues(DefaultServerName, "GET /metrics-slow", http.MethodGet).Write(pb))
+		return pb.GetGauge().GetValue()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := http.Get(base + "/metrics-slow")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	require.Equal(t, 1.0, inFlight())
+	close(release)
+	<-done
+	require.Eventually(t, func() bool { return inFlight() == 0 }, time.Second, 10*time.Millisecond)
+}
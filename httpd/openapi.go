@@ -0,0 +1,288 @@
+package httpd
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/donkeywon/golib/buildinfo"
+	"github.com/donkeywon/golib/util/httpu"
+)
+
+const openAPIVersion = "3.0.3"
+
+var (
+	pathWildcardRegexp = regexp.MustCompile(`\{([^}.$]+)(\.\.\.)?\}`)
+
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+type openAPISchemas map[string]interface{}
+
+func (s *Server) openAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	httpu.RespJSONOk(s.openAPIDoc(), w)
+}
+
+func (s *Server) openAPIDoc() map[string]interface{} {
+	s.mu.RLock()
+	routes := make([]*route, len(s.routes))
+	copy(routes, s.routes)
+	s.mu.RUnlock()
+
+	schemas := make(openAPISchemas)
+	paths := make(map[string]map[string]interface{})
+	for _, rt := range routes {
+		p := openAPIPath(rt.path)
+		if paths[p] == nil {
+			paths[p] = make(map[string]interface{})
+		}
+
+		method := strings.ToLower(rt.method)
+		if method == "" {
+			method = "get"
+			if rt.kind == HandlerKindTyped {
+				method = "post"
+			}
+		}
+		if method == "head" && paths[p]["get"] != nil {
+			continue
+		}
+		paths[p][method] = rt.openAPIOperation(method, schemas)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   s.name,
+			"version": buildinfo.Version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+func (rt *route) openAPIOperation(method string, schemas openAPISchemas) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary":     rt.pattern,
+		"operationId": rt.pattern,
+		"tags":        []string{rt.kind},
+	}
+	if rt.method == "" {
+		op["x-any-method"] = true
+	}
+
+	var params []interface{}
+	for _, m := range pathWildcardRegexp.FindAllStringSubmatch(rt.path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+
+	if rt.reqType != nil {
+		switch method {
+		case "get", "head", "options":
+			params = append(params, queryParams(rt.reqType, schemas)...)
+		default:
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					httpu.ContentTypeJSON: map[string]interface{}{"schema": schemaOf(rt.reqType, schemas)},
+				},
+			}
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	resp := map[string]interface{}{"description": "OK"}
+	switch rt.kind {
+	case HandlerKindTyped:
+		resp["content"] = map[string]interface{}{
+			httpu.ContentTypeJSON: map[string]interface{}{"schema": respEnvelopeSchema(schemaOf(rt.respType, schemas))},
+		}
+	case HandlerKindREST:
+		resp["content"] = map[string]interface{}{
+			httpu.ContentTypeJSON: map[string]interface{}{"schema": map[string]interface{}{}},
+		}
+	case HandlerKindSSE:
+		resp["content"] = map[string]interface{}{
+			ContentTypeEventStream: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+		}
+	}
+	op["responses"] = map[string]interface{}{"200": resp}
+	return op
+}
+
+// openAPIPath converts ServeMux pattern path to OpenAPI path, e.g. /files/{path...} to /files/{path}.
+func openAPIPath(p string) string {
+	p = strings.TrimSuffix(p, "{$}")
+	return pathWildcardRegexp.ReplaceAllString(p, "{$1}")
+}
+
+func respEnvelopeSchema(data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"code": map[string]interface{}{"type": "integer"},
+			"msg":  map[string]interface{}{"type": "string"},
+			"data": data,
+		},
+	}
+}
+
+func queryParams(t reflect.Type, schemas openAPISchemas) []interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []interface{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get(tagPath) != "" {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			params = append(params, queryParams(f.Type, schemas)...)
+			continue
+		}
+		params = append(params, map[string]interface{}{
+			"name":     formFieldName(f),
+			"in":       "query",
+			"required": isRequiredField(f),
+			"schema":   schemaOf(f.Type, schemas),
+		})
+	}
+	return params
+}
+
+func schemaOf(t reflect.Type, schemas openAPISchemas) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		return structSchemaRef(t, schemas)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchemaRef(t reflect.Type, schemas openAPISchemas) map[string]interface{} {
+	name := schemaName(t)
+	if name == "" {
+		return structSchema(t, schemas)
+	}
+
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if _, exists := schemas[name]; exists {
+		return ref
+	}
+	// placeholder to stop recursion of self-referencing types
+	schemas[name] = map[string]interface{}{}
+	schemas[name] = structSchema(t, schemas)
+	return ref
+}
+
+func structSchema(t reflect.Type, schemas openAPISchemas) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	collectProperties(t, schemas, props, &required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func collectProperties(t reflect.Type, schemas openAPISchemas, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get(tagJSON), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			collectProperties(f.Type, schemas, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = schemaOf(f.Type, schemas)
+		if isRequiredField(f) {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func isRequiredField(f reflect.StructField) bool {
+	for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	name := t.Name()
+	if pkg != "" {
+		name = pkg + "." + name
+	}
+	// generic type name contains chars not allowed in schema name
+	return strings.NewReplacer("[", "_", "]", "_", "*", "", "/", "_", ",", "_", " ", "").Replace(name)
+}
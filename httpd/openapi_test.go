@@ -0,0 +1,102 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type openAPITestUser struct {
+	ID      int                `json:"id"`
+	Name    string             `json:"name" validate:"required"`
+	Friends []*openAPITestUser `json:"friends,omitempty"`
+	Created time.Time          `json:"created"`
+	Secret  string             `json:"-"`
+}
+
+type openAPITestQuery struct {
+	ID      int  `path:"id"`
+	Verbose bool `form:"verbose"`
+	Limit   int  `json:"limit" validate:"required"`
+}
+
+// jsonPath returns the value at keys of v marshaled as json, for asserting generated documents.
+func jsonPath(t *testing.T, v interface{}, keys ...string) interface{} {
+	bs, err := json.Marshal(v)
+	require.NoError(t, err)
+	var m interface{}
+	require.NoError(t, json.Unmarshal(bs, &m))
+	for _, key := range keys {
+		obj, ok := m.(map[string]interface{})
+		require.True(t, ok, "%s is not an object", key)
+		m, ok = obj[key]
+		require.True(t, ok, "%s not found", key)
+	}
+	return m
+}
+
+func TestOpenAPIDoc(t *testing.T) {
+	getUser := TypedHandler[openAPITestQuery, openAPITestUser](func(context.Context, *openAPITestQuery) (*openAPITestUser, error) {
+		return nil, nil
+	})
+	createUser := TypedHandler[openAPITestUser, openAPITestUser](func(context.Context, *openAPITestUser) (*openAPITestUser, error) {
+		return nil, nil
+	})
+
+	s := newHttpd().GetServer(DefaultServerName)
+	api := s.Group("/api")
+	api.Handle("GET /users/{id}", getUser)
+	api.Handle("POST /users", createUser)
+	s.HandleFunc("/files/{path...}", func(http.ResponseWriter, *http.Request) {})
+	s.HandleFunc("GET /{$}", func(http.ResponseWriter, *http.Request) {})
+	doc := s.openAPIDoc()
+
+	require.Equal(t, openAPIVersion, jsonPath(t, doc, "openapi"))
+	require.Equal(t, DefaultServerName, jsonPath(t, doc, "info", "title"))
+
+	get := jsonPath(t, doc, "paths", "/api/users/{id}", "get")
+	require.Equal(t, []interface{}{HandlerKindTyped}, jsonPath(t, get, "tags"))
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+		map[string]interface{}{"name": "verbose", "in": "query", "required": false, "schema": map[string]interface{}{"type": "boolean"}},
+		map[string]interface{}{"name": "limit", "in": "query", "required": true, "schema": map[string]interface{}{"type": "integer", "format": "int64"}},
+	}, jsonPath(t, get, "parameters"))
+	userRef := map[string]interface{}{"$ref": "#/components/schemas/httpd.openAPITestUser"}
+	require.Equal(t, userRef, jsonPath(t, get, "responses", "200", "content", "application/json", "schema", "properties", "data"))
+
+	post := jsonPath(t, doc, "paths", "/api/users", "post")
+	require.Equal(t, userRef, jsonPath(t, post, "requestBody", "content", "application/json", "schema"))
+
+	user := jsonPath(t, doc, "components", "schemas", "httpd.openAPITestUser")
+	require.Equal(t, []interface{}{"name"}, jsonPath(t, user, "required"))
+	require.Equal(t, map[string]interface{}{
+		"id":      map[string]interface{}{"type": "integer", "format": "int64"},
+		"name":    map[string]interface{}{"type": "string"},
+		"friends": map[string]interface{}{"type": "array", "items": userRef},
+		"created": map[string]interface{}{"type": "string", "format": "date-time"},
+	}, jsonPath(t, user, "properties"))
+
+	// pattern without method serves any method, wildcards and {$} are converted to OpenAPI paths
+	require.Equal(t, true, jsonPath(t, doc, "paths", "/files/{path}", "get", "x-any-method"))
+	require.NotNil(t, jsonPath(t, doc, "paths", "/", "get"))
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	cfg := NewCfg()
+	cfg.OpenAPIPath = "GET /openapi.json"
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /users", func(http.ResponseWriter, *http.Request) {})
+	})
+
+	resp, err := http.Get(base + "/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var doc interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	require.Equal(t, "GET /users", jsonPath(t, doc, "paths", "/users", "get", "summary"))
+}
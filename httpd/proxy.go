@@ -0,0 +1,357 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+const (
+	BalanceRoundRobin = "round_robin"
+	BalanceLeastConn  = "least_conn"
+
+	defaultProxyHealthCheckInterval = 10 * time.Second
+	defaultProxyHealthCheckTimeout  = 2 * time.Second
+)
+
+var ErrNoHealthyTarget = errors.New("no healthy proxy target")
+
+type ProxyOptions struct {
+	// Balance is BalanceRoundRobin or BalanceLeastConn, default BalanceRoundRobin.
+	Balance string
+
+	// HealthCheckPath is requested with GET on each target every HealthCheckInterval, e.g. /healthz,
+	// a target is not balanced to until it responds 2xx or 3xx again. Health check is disabled if empty.
+	HealthCheckPath     string
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+
+	// Retries is the max times a request is retried on other targets when the target is unreachable,
+	// only requests with idempotent method and without body are retried, responses of target are never retried.
+	Retries int
+
+	// StripPrefix is removed from request path before proxying, e.g. /api for pattern /api/.
+	StripPrefix string
+	// PreserveHost sends the Host of request to target instead of the host of target.
+	PreserveHost bool
+	// TrustForwardedHeaders keeps X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto of request and appends
+	// client address to X-Forwarded-For, they are replaced by default since clients can forge them.
+	// Only enable it behind a trusted proxy.
+	TrustForwardedHeaders bool
+	// RequestHeaders are set to requests sent to target, a header with empty value is removed.
+	RequestHeaders map[string]string
+	// ResponseHeaders are set to responses of target, a header with empty value is removed.
+	ResponseHeaders map[string]string
+
+	// FlushInterval see httputil.ReverseProxy, responses are flushed immediately if negative.
+	FlushInterval time.Duration
+	// Transport is used to request targets and health checks, default is a clone of http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+type proxyTarget struct {
+	url     *url.URL
+	active  atomic.Int64
+	healthy atomic.Bool
+}
+
+// proxyHandler balances requests to targets by httputil.ReverseProxy.
+type proxyHandler struct {
+	opts    *ProxyOptions
+	targets []*proxyTarget
+	rp      *httputil.ReverseProxy
+	next    atomic.Uint64
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// proxyAttempt is the state of one attempt of a request shared by the callbacks of httputil.ReverseProxy.
+type proxyAttempt struct {
+	target   *proxyTarget
+	canRetry bool
+	retry    bool
+	err      error
+}
+
+type ctxKeyProxyAttempt struct{}
+
+func newProxyHandler(targets []string, opts *ProxyOptions) (*proxyHandler, error) {
+	if len(targets) == 0 {
+		return nil, errs.Errorf("proxy targets is empty")
+	}
+	o := ProxyOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	opts = &o
+	switch opts.Balance {
+	case "":
+		opts.Balance = BalanceRoundRobin
+	case BalanceRoundRobin, BalanceLeastConn:
+	default:
+		return nil, errs.Errorf("invalid proxy balance: %s", opts.Balance)
+	}
+	if opts.HealthCheckInterval <= 0 {
+		opts.HealthCheckInterval = defaultProxyHealthCheckInterval
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = defaultProxyHealthCheckTimeout
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	p := &proxyHandler{
+		opts:    opts,
+		targets: make([]*proxyTarget, 0, len(targets)),
+		closeCh: make(chan struct{}),
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid proxy target: %s", target)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, errs.Errorf("invalid proxy target: %s, must be http(s)://host[:port][/path]", target)
+		}
+		pt := &proxyTarget{url: u}
+		pt.healthy.Store(true)
+		p.targets = append(p.targets, pt)
+	}
+
+	p.rp = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      opts.Transport,
+		FlushInterval:  opts.FlushInterval,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.errorHandler,
+	}
+	return p, nil
+}
+
+// ServeHTTP proxies request to a healthy target, and retries on other targets if the target is unreachable.
+func (p *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	canRetry := p.opts.Retries > 0 && isIdempotent(r.Method) && (r.Body == nil || r.Body == http.NoBody)
+	tried := make(map[*proxyTarget]struct{}, 1)
+	for {
+		target := p.pick(tried)
+		if target == nil {
+			LoggerFromCtx(r.Context()).Warn("proxy fail", "err", ErrNoHealthyTarget, "attempts", len(tried))
+			status := http.StatusServiceUnavailable
+			if len(tried) > 0 {
+				// the last attempt failed and no other target to retry on
+				status = http.StatusBadGateway
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		tried[target] = struct{}{}
+		AddLogFields(r.Context(), "upstream", target.url.Host, "upstream_attempts", len(tried))
+
+		attempt := &proxyAttempt{
+			target:   target,
+			canRetry: canRetry && len(tried) <= p.opts.Retries && len(tried) < len(p.targets),
+		}
+		target.active.Add(1)
+		p.rp.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyProxyAttempt{}, attempt)))
+		target.active.Add(-1)
+		if !attempt.retry {
+			return
+		}
+		LoggerFromCtx(r.Context()).Warn("proxy to target fail, retry", "err", attempt.err, "upstream", target.url.Host)
+	}
+}
+
+// pick returns a healthy target not tried yet, nil if none.
+func (p *proxyHandler) pick(tried map[*proxyTarget]struct{}) *proxyTarget {
+	candidates := make([]*proxyTarget, 0, len(p.targets))
+	for _, t := range p.targets {
+		if _, ok := tried[t]; !ok && t.healthy.Load() {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	n := len(candidates)
+	start := int(p.next.Add(1) % uint64(n))
+	picked := candidates[start]
+	if p.opts.Balance == BalanceLeastConn {
+		// start from the round-robin one so that idle targets are used evenly
+		for i := 1; i < n; i++ {
+			if t := candidates[(start+i)%n]; t.active.Load() < picked.active.Load() {
+				picked = t
+			}
+		}
+	}
+	return picked
+}
+
+func (p *proxyHandler) rewrite(pr *httputil.ProxyRequest) {
+	attempt := pr.In.Context().Value(ctxKeyProxyAttempt{}).(*proxyAttempt)
+
+	if p.opts.StripPrefix != "" {
+		pr.Out.URL.Path = ensureLeadingSlash(strings.TrimPrefix(pr.Out.URL.Path, p.opts.StripPrefix))
+		if pr.Out.URL.RawPath != "" {
+			pr.Out.URL.RawPath = ensureLeadingSlash(strings.TrimPrefix(pr.Out.URL.RawPath, p.opts.StripPrefix))
+		}
+	}
+	pr.SetURL(attempt.target.url)
+	if p.opts.PreserveHost {
+		pr.Out.Host = pr.In.Host
+	}
+	if p.opts.TrustForwardedHeaders {
+		pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+	}
+	pr.SetXForwarded()
+	if p.opts.TrustForwardedHeaders {
+		for _, k := range []string{"X-Forwarded-Host", "X-Forwarded-Proto"} {
+			if v := pr.In.Header.Get(k); v != "" {
+				pr.Out.Header.Set(k, v)
+			}
+		}
+	}
+	if reqID := RequestID(pr.In.Context()); reqID != "" {
+		pr.Out.Header.Set(HeaderRequestID, reqID)
+	}
+	setHeaders(pr.Out.Header, p.opts.RequestHeaders)
+}
+
+func (p *proxyHandler) modifyResponse(resp *http.Response) error {
+	setHeaders(resp.Header, p.opts.ResponseHeaders)
+	return nil
+}
+
+// errorHandler marks the attempt to be retried instead of responding if it can be retried,
+// it is only called before anything is written to client.
+func (p *proxyHandler) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	attempt := r.Context().Value(ctxKeyProxyAttempt{}).(*proxyAttempt)
+	attempt.err = err
+	if attempt.canRetry && r.Context().Err() == nil {
+		attempt.retry = true
+		return
+	}
+
+	if errors.Is(err, context.Canceled) {
+		// client gone, nothing to respond
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	LoggerFromCtx(r.Context()).Error("proxy to target fail", err, "upstream", attempt.target.url.Host)
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+// start runs health checks until s stopping or the handler closed.
+func (p *proxyHandler) start(s *Server) {
+	if p.opts.HealthCheckPath == "" {
+		return
+	}
+
+	go func() {
+		t := time.NewTicker(p.opts.HealthCheckInterval)
+		defer t.Stop()
+		for {
+			p.checkTargets(s)
+			select {
+			case <-s.h.Stopping():
+				return
+			case <-p.closeCh:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+func (p *proxyHandler) checkTargets(s *Server) {
+	wg := sync.WaitGroup{}
+	for _, t := range p.targets {
+		wg.Add(1)
+		go func(t *proxyTarget) {
+			defer wg.Done()
+			err := p.checkTarget(t)
+			if t.healthy.Swap(err == nil) == (err == nil) {
+				return
+			}
+			if err != nil {
+				s.h.Warn("proxy target unhealthy", "server", s.name, "target", t.url.String(), "err", err)
+			} else {
+				s.h.Info("proxy target healthy", "server", s.name, "target", t.url.String())
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (p *proxyHandler) checkTarget(t *proxyTarget) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.HealthCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url.JoinPath(p.opts.HealthCheckPath).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.opts.Transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// close stops health checks, it is called when the route is removed.
+func (p *proxyHandler) close() {
+	p.closeOnce.Do(func() {
+		close(p.closeCh)
+	})
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func ensureLeadingSlash(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		return "/" + p
+	}
+	return p
+}
+
+func setHeaders(h http.Header, headers map[string]string) {
+	for k, v := range headers {
+		if v == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, v)
+		}
+	}
+}
+
+func (s *Server) handleProxy(pattern string, targets []string, opts *ProxyOptions, mfs []MiddlewareFunc, rc *RouteCfg) error {
+	p, err := newProxyHandler(targets, opts)
+	if err != nil {
+		return err
+	}
+	s.handle(pattern, p, mfs, rc)
+	p.start(s)
+	return nil
+}
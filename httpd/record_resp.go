@@ -45,6 +45,7 @@ func (rp *recordResponseWriter) writeHeader() {
 func (rp *recordResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if rp.nw == -1 {
 		rp.nw = 0
+		rp.statusCode = http.StatusSwitchingProtocols
 	}
 	return rp.ResponseWriter.(http.Hijacker).Hijack()
 }
@@ -53,3 +54,7 @@ func (rp *recordResponseWriter) Flush() {
 	rp.writeHeader()
 	rp.ResponseWriter.(http.Flusher).Flush()
 }
+
+func (rp *recordResponseWriter) Unwrap() http.ResponseWriter {
+	return rp.ResponseWriter
+}
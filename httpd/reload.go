@@ -0,0 +1,265 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util"
+)
+
+// Reload applies cfg to running servers without restarting, timeouts and middleware options take effect
+// for new requests immediately. If listen addresses changed, new addresses are bound before the removed ones are closed.
+// All servers are prepared before any of them is changed, nothing changes if any of them fails.
+// In-flight requests are drained by the previous http servers in background, until finished or ShutdownTimeout if positive,
+// they are closed when the server stops.
+// Tls options and the set of named servers can not be reloaded.
+func (h *Httpd) Reload(cfg *Cfg) error {
+	err := util.V.Struct(cfg)
+	if err != nil {
+		return errs.Wrap(err, "invalid cfg")
+	}
+	if _, exists := cfg.Servers[DefaultServerName]; exists {
+		return errs.Errorf("server name %s is reserved", DefaultServerName)
+	}
+
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	h.mu.Lock()
+	servers := make(map[string]*Server, len(h.servers))
+	for name, s := range h.servers {
+		servers[name] = s
+	}
+	names := h.serverNames()
+	h.mu.Unlock()
+
+	cfgs := map[string]*Cfg{DefaultServerName: cfg}
+	for name, c := range cfg.Servers {
+		cfgs[name] = c
+	}
+	for name, s := range servers {
+		_, exists := cfgs[name]
+		if exists != (s.Cfg() != nil) {
+			return errs.Errorf("server %s can not be added or removed by reload", name)
+		}
+	}
+	for name, c := range cfgs {
+		if c == nil {
+			return errs.Errorf("server %s has no cfg", name)
+		}
+		s, exists := servers[name]
+		if !exists {
+			return errs.Errorf("server %s can not be added by reload", name)
+		}
+		err = checkReloadable(s.Cfg(), c)
+		if err != nil {
+			return errs.Wrapf(err, "server %s can not be reloaded", name)
+		}
+	}
+
+	var reloading []*Server
+	for _, name := range names {
+		if _, exists := cfgs[name]; exists {
+			reloading = append(reloading, servers[name])
+		}
+	}
+	// servers can not be started or stopped while reloading
+	for _, s := range reloading {
+		s.srvMu.Lock()
+	}
+	unlock := func() {
+		for _, s := range reloading {
+			s.srvMu.Unlock()
+		}
+	}
+
+	plans := make([]*reloadPlan, 0, len(reloading))
+	for _, s := range reloading {
+		p, err := s.prepareReload(cfgs[s.name])
+		if err != nil {
+			for _, p := range plans {
+				p.rollback()
+			}
+			unlock()
+			return errs.Wrapf(err, "reload server %s fail", s.name)
+		}
+		plans = append(plans, p)
+	}
+	for _, p := range plans {
+		p.commit()
+	}
+	unlock()
+
+	for _, p := range plans {
+		p.finish()
+	}
+
+	h.mu.Lock()
+	h.Cfg = cfg
+	h.mu.Unlock()
+	return nil
+}
+
+func checkReloadable(old *Cfg, cfg *Cfg) error {
+	if old.TLSCertFile != cfg.TLSCertFile || old.TLSKeyFile != cfg.TLSKeyFile || old.TLSClientCAFile != cfg.TLSClientCAFile ||
+		old.TLSMinVersion != cfg.TLSMinVersion || !slices.Equal(old.TLSCipherSuites, cfg.TLSCipherSuites) ||
+		old.DisableTLSCertReload != cfg.DisableTLSCertReload {
+		return errs.New("tls options changed")
+	}
+	return nil
+}
+
+// reloadPlan is a server prepared to reload, it is committed only if all servers are prepared.
+type reloadPlan struct {
+	s      *Server
+	oldCfg *Cfg
+	st     *serverState
+	added  []string
+
+	// nil if the server is not started
+	srv       *http.Server
+	ct        *connTracker
+	listeners map[string][]*sharedListener
+
+	// set by commit
+	oldSt  *serverState
+	oldSrv *http.Server
+	oldCt  *connTracker
+}
+
+// prepareReload builds state, built-in handlers, listeners and http server of cfg without serving,
+// must be called with srvMu held.
+func (s *Server) prepareReload(cfg *Cfg) (*reloadPlan, error) {
+	if s.stopped {
+		return nil, ErrServerStopped
+	}
+
+	st, err := s.newState(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p := &reloadPlan{s: s, oldCfg: s.Cfg(), st: st}
+
+	p.added, err = s.addBuiltinHandlers(p.oldCfg, cfg)
+	if err != nil {
+		return nil, errs.Wrap(err, "register built-in handlers fail")
+	}
+	if s.s == nil {
+		// not started yet
+		return p, nil
+	}
+
+	p.listeners, err = s.listen(cfg, s.listeners)
+	if err != nil {
+		p.rollback()
+		return nil, err
+	}
+	p.srv, p.ct, err = s.buildHTTPServer(st)
+	if err != nil {
+		p.rollback()
+		return nil, err
+	}
+	return p, nil
+}
+
+// rollback releases what prepareReload acquired, must be called with srvMu held.
+func (p *reloadPlan) rollback() {
+	for addr, sls := range p.listeners {
+		if _, reused := p.s.listeners[addr]; !reused {
+			closeSharedListeners(map[string][]*sharedListener{addr: sls})
+		}
+	}
+	p.s.unhandleAll(p.added)
+}
+
+// commit replaces state and http server of the server, must be called with srvMu held.
+func (p *reloadPlan) commit() {
+	s := p.s
+	p.oldSt = s.st.Swap(p.st)
+	if p.srv == nil {
+		return
+	}
+
+	for addr, sls := range s.listeners {
+		if _, reused := p.listeners[addr]; !reused {
+			s.h.Info("stop listening", "server", s.name, "addr", addr)
+			closeSharedListeners(map[string][]*sharedListener{addr: sls})
+		}
+	}
+	p.oldSrv, p.oldCt = s.s, s.connTracker
+	s.listeners = p.listeners
+	s.serve(p.srv, p.ct)
+	if s.draining == nil {
+		s.draining = make(map[*http.Server]*connTracker)
+	}
+	s.draining[p.oldSrv] = p.oldCt
+}
+
+// finish removes built-in handlers disabled and drains the previous http server in background.
+func (p *reloadPlan) finish() {
+	s := p.s
+	s.removeBuiltinHandlers(p.oldCfg, p.st.cfg)
+	s.runState(p.st)
+	close(p.oldSt.done)
+	s.h.Info("server reloaded", "server", s.name)
+
+	if p.oldSrv != nil {
+		go s.drain(p.oldSrv, p.oldCt, p.oldSt.cfg.ShutdownTimeout)
+	}
+}
+
+// drain waits in-flight requests of srv replaced by reload to finish, in timeout if positive.
+func (s *Server) drain(srv *http.Server, ct *connTracker, timeout time.Duration) {
+	var err error
+	if timeout > 0 {
+		err = s.shutdown(srv, ct, timeout)
+	} else {
+		err = srv.Shutdown(context.Background())
+	}
+	if err != nil {
+		s.h.Error("drain previous http server fail", err, "server", s.name)
+	}
+
+	s.srvMu.Lock()
+	delete(s.draining, srv)
+	s.srvMu.Unlock()
+}
+
+// addBuiltinHandlers registers built-in endpoints enabled in cfg but not in old, returns the added patterns,
+// nothing is added if any of them fails.
+func (s *Server) addBuiltinHandlers(old *Cfg, cfg *Cfg) ([]string, error) {
+	oldHandlers := s.builtinHandlers(old)
+
+	var added []string
+	for pattern, handler := range s.builtinHandlers(cfg) {
+		if _, exists := oldHandlers[pattern]; exists {
+			continue
+		}
+		err := s.addRoute(pattern, handler, nil, nil)
+		if err != nil {
+			s.unhandleAll(added)
+			return nil, err
+		}
+		added = append(added, pattern)
+	}
+	return added, nil
+}
+
+// removeBuiltinHandlers removes built-in endpoints enabled in old but not in cfg.
+func (s *Server) removeBuiltinHandlers(old *Cfg, cfg *Cfg) {
+	handlers := s.builtinHandlers(cfg)
+	for pattern := range s.builtinHandlers(old) {
+		if _, exists := handlers[pattern]; !exists {
+			s.Unhandle(pattern)
+		}
+	}
+}
+
+func (s *Server) unhandleAll(patterns []string) {
+	for _, p := range patterns {
+		s.Unhandle(p)
+	}
+}
@@ -1,17 +1,31 @@
 package httpd
 
 import (
+	"errors"
 	"net/http"
+	"sync"
 
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/util/httpu"
+	"github.com/go-playground/validator/v10"
 )
 
 type RespCode int
 
 const (
-	RespCodeOk   RespCode = 0
-	RespCodeFail RespCode = 1
+	RespCodeOk              RespCode = 0
+	RespCodeFail            RespCode = 1
+	RespCodeInvalidArgument RespCode = 2
+	RespCodeNotFound        RespCode = 3
+	RespCodeConflict        RespCode = 4
+	RespCodeUnavailable     RespCode = 5
+)
+
+var (
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrUnavailable     = errors.New("unavailable")
 )
 
 type Resp struct {
@@ -20,6 +34,74 @@ type Resp struct {
 	Data interface{} `json:"data"`
 }
 
+type errMapping struct {
+	match  func(error) bool
+	status int
+	code   RespCode
+}
+
+var (
+	_errMappingsMu sync.RWMutex
+	_errMappings   []errMapping
+)
+
+func init() {
+	RegisterErr(ErrInvalidArgument, http.StatusBadRequest, RespCodeInvalidArgument)
+	RegisterErr(ErrNotFound, http.StatusNotFound, RespCodeNotFound)
+	RegisterErr(ErrConflict, http.StatusConflict, RespCodeConflict)
+	RegisterErr(ErrUnavailable, http.StatusServiceUnavailable, RespCodeUnavailable)
+	RegisterErrType[*badRequestError](http.StatusBadRequest, RespCodeInvalidArgument)
+	RegisterErrType[validator.ValidationErrors](http.StatusBadRequest, RespCodeInvalidArgument)
+	RegisterErrType[*http.MaxBytesError](http.StatusRequestEntityTooLarge, RespCodeInvalidArgument)
+}
+
+// RegisterErr maps errors matching target by errors.Is to http status and RespCode,
+// later registered mapping takes precedence.
+func RegisterErr(target error, status int, code RespCode) {
+	registerErrMapping(func(err error) bool { return errors.Is(err, target) }, status, code)
+}
+
+// RegisterErrType maps errors matching type T by errors.As to http status and RespCode,
+// later registered mapping takes precedence.
+func RegisterErrType[T error](status int, code RespCode) {
+	registerErrMapping(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, status, code)
+}
+
+func registerErrMapping(match func(error) bool, status int, code RespCode) {
+	_errMappingsMu.Lock()
+	defer _errMappingsMu.Unlock()
+	_errMappings = append(_errMappings, errMapping{match: match, status: status, code: code})
+}
+
+// ErrToStatus returns the http status and RespCode mapped to err, 500 and RespCodeFail if no mapping matched.
+func ErrToStatus(err error) (int, RespCode) {
+	_errMappingsMu.RLock()
+	defer _errMappingsMu.RUnlock()
+	for i := len(_errMappings) - 1; i >= 0; i-- {
+		if _errMappings[i].match(err) {
+			return _errMappings[i].status, _errMappings[i].code
+		}
+	}
+	return http.StatusInternalServerError, RespCodeFail
+}
+
+// RespErr responds err in the Resp envelope with the status and RespCode mapped to err.
+// Errors with 5xx status are logged, and responded with the status text if HideStackTrace is set.
+func RespErr(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := ErrToStatus(err)
+	msg := err.Error()
+	if status >= http.StatusInternalServerError {
+		LoggerFromCtx(r.Context()).Error("handle req fail", err, "status", status)
+		if hideStackTrace(r) {
+			msg = http.StatusText(status)
+		}
+	}
+	httpu.RespJSON(status, &Resp{Code: code, Msg: msg}, w)
+}
+
 func RestRecoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -29,9 +111,10 @@ func RestRecoverMiddleware(next http.Handler) http.Handler {
 			}
 
 			err := errs.PanicToErr(e)
+			LoggerFromCtx(r.Context()).Error("handle rest req fail, panic occurred", err)
 			resp := &Resp{
 				Code: RespCodeFail,
-				Msg:  errs.ErrToStackString(err),
+				Msg:  panicMsg(r, err),
 			}
 			httpu.RespJSONFail(resp, w)
 		}()
@@ -39,3 +122,28 @@ func RestRecoverMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// panicMsg returns the message of panic responded to client, stack trace is hidden if HideStackTrace is set.
+func panicMsg(r *http.Request, err error) string {
+	if hideStackTrace(r) {
+		return http.StatusText(http.StatusInternalServerError)
+	}
+	return errs.ErrToStackString(err)
+}
+
+func hideStackTrace(r *http.Request) bool {
+	rt := routeFromCtx(r.Context())
+	return rt != nil && rt.s.Cfg() != nil && rt.s.Cfg().HideStackTrace
+}
+
+type badRequestError struct {
+	err error
+}
+
+func (e *badRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *badRequestError) Unwrap() error {
+	return e.err
+}
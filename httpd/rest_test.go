@@ -0,0 +1,112 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/stretchr/testify/require"
+)
+
+type quotaError struct{ user string }
+
+func (e *quotaError) Error() string {
+	return "quota exceeded: " + e.user
+}
+
+func TestErrToStatus(t *testing.T) {
+	errMaintenance := errors.New("maintenance")
+	RegisterErr(errMaintenance, http.StatusServiceUnavailable, RespCode(100))
+	RegisterErrType[*quotaError](http.StatusTooManyRequests, RespCode(101))
+
+	tests := []struct {
+		err    error
+		status int
+		code   RespCode
+	}{
+		{err: ErrNotFound, status: http.StatusNotFound, code: RespCodeNotFound},
+		{err: errs.Wrap(ErrConflict, "user 1"), status: http.StatusConflict, code: RespCodeConflict},
+		{err: fmt.Errorf("check: %w", ErrInvalidArgument), status: http.StatusBadRequest, code: RespCodeInvalidArgument},
+		{err: ErrUnavailable, status: http.StatusServiceUnavailable, code: RespCodeUnavailable},
+		{err: &http.MaxBytesError{Limit: 1}, status: http.StatusRequestEntityTooLarge, code: RespCodeInvalidArgument},
+		{err: errs.Wrap(errMaintenance, "db"), status: http.StatusServiceUnavailable, code: RespCode(100)},
+		{err: errs.Wrap(&quotaError{user: "a"}, "upload"), status: http.StatusTooManyRequests, code: RespCode(101)},
+		{err: errors.New("unknown"), status: http.StatusInternalServerError, code: RespCodeFail},
+	}
+	for _, tt := range tests {
+		status, code := ErrToStatus(tt.err)
+		require.Equal(t, tt.status, status, tt.err.Error())
+		require.Equal(t, tt.code, code, tt.err.Error())
+	}
+
+	// later registered mapping takes precedence
+	errGone := errors.New("gone")
+	RegisterErr(errGone, http.StatusNotFound, RespCodeNotFound)
+	RegisterErr(errGone, http.StatusGone, RespCode(102))
+	status, code := ErrToStatus(errGone)
+	require.Equal(t, http.StatusGone, status)
+	require.Equal(t, RespCode(102), code)
+}
+
+func TestRespErrHideStackTrace(t *testing.T) {
+	type req struct {
+		Err string `form:"err"`
+	}
+	handler := TypedHandler[req, struct{}](func(_ context.Context, r *req) (*struct{}, error) {
+		if r.Err == "not_found" {
+			return nil, errs.Wrap(ErrNotFound, "user 1")
+		}
+		return nil, errors.New("dial db 10.0.0.1 fail")
+	})
+
+	for _, hide := range []bool{false, true} {
+		cfg := NewCfg()
+		cfg.HideStackTrace = hide
+		base := startHttpd(t, cfg, func(h *Httpd) {
+			s := h.GetServer(DefaultServerName)
+			s.Handle("/err", handler)
+			s.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) {
+				panic("secret 10.0.0.1")
+			})
+		})
+
+		tests := []struct {
+			query  string
+			status int
+			msg    string
+		}{
+			{query: "err=not_found", status: http.StatusNotFound, msg: "user 1: not found"},
+			{query: "err=internal", status: http.StatusInternalServerError, msg: "dial db 10.0.0.1 fail"},
+		}
+		if hide {
+			tests[1].msg = http.StatusText(http.StatusInternalServerError)
+		}
+		for _, tt := range tests {
+			resp, err := http.Get(base + "/err?" + tt.query)
+			require.NoError(t, err)
+			r := &Resp{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(r))
+			_ = resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+			require.Equal(t, tt.msg, r.Msg)
+		}
+
+		// panic message with stack trace is hidden too
+		resp, err := http.Get(base + "/panic")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		if hide {
+			require.Equal(t, http.StatusText(http.StatusInternalServerError), string(body))
+		} else {
+			require.Contains(t, string(body), "secret 10.0.0.1")
+		}
+	}
+}
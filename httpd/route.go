@@ -0,0 +1,136 @@
+package httpd
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/donkeywon/golib/util/httpu"
+)
+
+const (
+	HandlerKindHandler = "handler"
+	HandlerKindFunc    = "func"
+	HandlerKindRaw     = "raw"
+	HandlerKindAPI     = "api"
+	HandlerKindREST    = "rest"
+	HandlerKindTyped   = "typed"
+	HandlerKindSSE     = "sse"
+	HandlerKindWS      = "ws"
+	HandlerKindProxy   = "proxy"
+	HandlerKindStatic  = "static"
+)
+
+type route struct {
+	s        *Server
+	pattern  string
+	method   string
+	host     string
+	path     string
+	kind     string
+	reqType  reflect.Type
+	respType reflect.Type
+	handler  http.Handler
+	cfg      *RouteCfg
+	chain    http.Handler
+}
+
+// typedHandler is implemented by handlers which know their request and response types, e.g. TypedHandler.
+type typedHandler interface {
+	types() (reflect.Type, reflect.Type)
+}
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Server   string `json:"server"             yaml:"server"`
+	Pattern  string `json:"pattern"            yaml:"pattern"`
+	Method   string `json:"method,omitempty"   yaml:"method,omitempty"`
+	Path     string `json:"path"               yaml:"path"`
+	Kind     string `json:"kind"               yaml:"kind"`
+	Request  string `json:"request,omitempty"  yaml:"request,omitempty"`
+	Response string `json:"response,omitempty" yaml:"response,omitempty"`
+}
+
+func newRoute(s *Server, pattern string, handler http.Handler, rc *RouteCfg) *route {
+	rt := &route{
+		s:       s,
+		pattern: pattern,
+		kind:    handlerKind(handler),
+		handler: handler,
+		cfg:     rc,
+	}
+
+	rest := strings.TrimSpace(pattern)
+	if method, p, found := strings.Cut(rest, " "); found {
+		rt.method = method
+		rest = strings.TrimLeft(p, " ")
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rt.host, rt.path = rest[:i], rest[i:]
+	} else {
+		rt.host = rest
+	}
+
+	if th, ok := handler.(typedHandler); ok {
+		rt.reqType, rt.respType = th.types()
+	}
+	return rt
+}
+
+func handlerKind(handler http.Handler) string {
+	switch handler.(type) {
+	case RawHandler:
+		return HandlerKindRaw
+	case APIHandler:
+		return HandlerKindAPI
+	case RESTHandler:
+		return HandlerKindREST
+	case http.HandlerFunc:
+		return HandlerKindFunc
+	case typedHandler:
+		return HandlerKindTyped
+	case SSEHandler:
+		return HandlerKindSSE
+	case WSHandler:
+		return HandlerKindWS
+	case *proxyHandler:
+		return HandlerKindProxy
+	case *staticHandler:
+		return HandlerKindStatic
+	default:
+		return HandlerKindHandler
+	}
+}
+
+func (rt *route) info() *RouteInfo {
+	ri := &RouteInfo{
+		Server:  rt.s.name,
+		Pattern: rt.pattern,
+		Method:  rt.method,
+		Path:    rt.path,
+		Kind:    rt.kind,
+	}
+	if rt.reqType != nil {
+		ri.Request = rt.reqType.String()
+	}
+	if rt.respType != nil {
+		ri.Response = rt.respType.String()
+	}
+	return ri
+}
+
+// Routes returns all routes registered on this server.
+func (s *Server) Routes() []*RouteInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]*RouteInfo, 0, len(s.routes))
+	for _, rt := range s.routes {
+		infos = append(infos, rt.info())
+	}
+	return infos
+}
+
+func (s *Server) routesHandler(w http.ResponseWriter, _ *http.Request) {
+	httpu.RespJSONOk(s.Routes(), w)
+}
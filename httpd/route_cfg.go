@@ -0,0 +1,121 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RouteCfg overrides server level timeouts and body size limit of a route, zero value means not override,
+// negative value means no timeout or no limit.
+type RouteCfg struct {
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	MaxBodyBytes int64         `yaml:"maxBodyBytes"`
+}
+
+// merge returns a RouteCfg with non-zero fields of override taking precedence over rc.
+func (rc *RouteCfg) merge(override *RouteCfg) *RouteCfg {
+	merged := &RouteCfg{}
+	for _, c := range []*RouteCfg{rc, override} {
+		if c == nil {
+			continue
+		}
+		if c.ReadTimeout != 0 {
+			merged.ReadTimeout = c.ReadTimeout
+		}
+		if c.WriteTimeout != 0 {
+			merged.WriteTimeout = c.WriteTimeout
+		}
+		if c.MaxBodyBytes != 0 {
+			merged.MaxBodyBytes = c.MaxBodyBytes
+		}
+	}
+	return merged
+}
+
+// routeCfgMiddleware applies RouteCfg of the route, cfg in Cfg.Routes takes precedence over the one set by code.
+func (s *Server) routeCfgMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := s.routeCfgOf(routeFromCtx(r.Context()))
+		if rc.MaxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, rc.MaxBodyBytes)
+		}
+
+		if rc.ReadTimeout != 0 || rc.WriteTimeout != 0 {
+			r = s.applyTimeouts(w, r, rc)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeCfgOf returns the RouteCfg in effect of rt, MaxBodyBytes falls back to the one of server.
+func (s *Server) routeCfgOf(rt *route) *RouteCfg {
+	cfg := s.Cfg()
+	rc := rt.cfg.merge(cfg.Routes[rt.pattern])
+	if rc.MaxBodyBytes == 0 {
+		rc.MaxBodyBytes = cfg.MaxBodyBytes
+	}
+	return rc
+}
+
+func (s *Server) applyTimeouts(w http.ResponseWriter, r *http.Request, rc *RouteCfg) *http.Request {
+	ctl := http.NewResponseController(w)
+	if rc.ReadTimeout != 0 {
+		err := ctl.SetReadDeadline(deadlineOf(rc.ReadTimeout))
+		if err != nil {
+			s.h.Warn("set read deadline fail", "server", s.name, "request_id", RequestID(r.Context()), "err", err, "timeout", rc.ReadTimeout)
+		}
+	}
+	if rc.WriteTimeout != 0 {
+		err := ctl.SetWriteDeadline(deadlineOf(rc.WriteTimeout))
+		if err != nil {
+			s.h.Warn("set write deadline fail", "server", s.name, "request_id", RequestID(r.Context()), "err", err, "timeout", rc.WriteTimeout)
+		}
+	}
+
+	cfg := s.Cfg()
+	return r.WithContext(context.WithValue(r.Context(), ctxKeyRouteTimeouts, &routeTimeouts{
+		read:  max(timeoutOf(rc.ReadTimeout, cfg.ReadTimeout), 0),
+		write: max(timeoutOf(rc.WriteTimeout, cfg.WriteTimeout), 0),
+	}))
+}
+
+type routeTimeouts struct {
+	read  time.Duration
+	write time.Duration
+}
+
+// RouteTimeouts returns read and write timeout in effect of the request, which are overridden by RouteCfg of the route
+// or the ones of server, zero means no timeout. http.Server in context always has the timeouts of server,
+// e.g. pprof.Profile refuses to profile longer than its WriteTimeout.
+func RouteTimeouts(ctx context.Context) (time.Duration, time.Duration) {
+	if rt, ok := ctx.Value(ctxKeyRouteTimeouts).(*routeTimeouts); ok {
+		return rt.read, rt.write
+	}
+	if rt := routeFromCtx(ctx); rt != nil && rt.s.Cfg() != nil {
+		cfg := rt.s.Cfg()
+		return max(cfg.ReadTimeout, 0), max(cfg.WriteTimeout, 0)
+	}
+	return 0, 0
+}
+
+// deadlineOf returns zero time which means no deadline if timeout is negative.
+func deadlineOf(timeout time.Duration) time.Time {
+	if timeout < 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+func timeoutOf(override time.Duration, timeout time.Duration) time.Duration {
+	switch {
+	case override < 0:
+		return 0
+	case override > 0:
+		return override
+	default:
+		return timeout
+	}
+}
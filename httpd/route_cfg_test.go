@@ -0,0 +1,47 @@
+package httpd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteTimeouts(t *testing.T) {
+	cfg := NewCfg()
+	cfg.ReadTimeout = time.Second
+	cfg.WriteTimeout = 2 * time.Second
+	cfg.Routes = map[string]*RouteCfg{"GET /no-timeout": {WriteTimeout: -1}}
+
+	timeouts := func(w http.ResponseWriter, r *http.Request) {
+		read, write := RouteTimeouts(r.Context())
+		srv := r.Context().Value(http.ServerContextKey).(*http.Server)
+		_, _ = fmt.Fprintf(w, "%s %s %s", read, write, srv.WriteTimeout)
+	}
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		s.HandleFunc("GET /default", timeouts)
+		s.HandleFunc("GET /no-timeout", timeouts)
+		s.Group("").WithRouteCfg(&RouteCfg{WriteTimeout: time.Minute}).HandleFunc("GET /long", timeouts)
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/default", want: "1s 2s 2s"},
+		{path: "/long", want: "1s 1m0s 2s"},
+		{path: "/no-timeout", want: "1s 0s 2s"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(base + tt.path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, tt.want, string(body), tt.path)
+	}
+}
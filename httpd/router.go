@@ -0,0 +1,106 @@
+package httpd
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// Router registers handlers on a Server with a path prefix and its own middlewares,
+// group middlewares run after the middlewares registered on Server.
+type Router struct {
+	s           *Server
+	prefix      string
+	middlewares []MiddlewareFunc
+	routeCfg    *RouteCfg
+}
+
+func (rt *Router) Group(prefix string, mf ...MiddlewareFunc) *Router {
+	middlewares := make([]MiddlewareFunc, 0, len(rt.middlewares)+len(mf))
+	middlewares = append(middlewares, rt.middlewares...)
+	middlewares = append(middlewares, mf...)
+	return &Router{
+		s:           rt.s,
+		prefix:      joinPath(rt.prefix, prefix),
+		middlewares: middlewares,
+		routeCfg:    rt.routeCfg,
+	}
+}
+
+// WithRouteCfg returns a Router which registers handlers with timeouts and body size limit overridden by rc,
+// RouteCfg of the same pattern in Cfg.Routes takes precedence.
+func (rt *Router) WithRouteCfg(rc *RouteCfg) *Router {
+	return &Router{
+		s:           rt.s,
+		prefix:      rt.prefix,
+		middlewares: rt.middlewares,
+		routeCfg:    rc,
+	}
+}
+
+// Use must called before Handle func below.
+func (rt *Router) Use(mf ...MiddlewareFunc) {
+	rt.middlewares = append(rt.middlewares, mf...)
+}
+
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), handler, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), handler, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleRaw(pattern string, handler RawHandler) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), handler, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleAPI(pattern string, handler APIHandler) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), handler, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleREST(pattern string, handler RESTHandler) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), handler, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleSSE(pattern string, handler SSEHandler) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), handler, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleWS(pattern string, handler WSHandler) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), handler, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleProxy(pattern string, targets []string, opts *ProxyOptions) error {
+	return rt.s.handleProxy(joinPattern(rt.prefix, pattern), targets, opts, rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) HandleStatic(pattern string, fsys fs.FS, opts *StaticOptions) {
+	rt.s.handle(joinPattern(rt.prefix, pattern), newStaticHandler(fsys, opts), rt.middlewares, rt.routeCfg)
+}
+
+func (rt *Router) Unhandle(pattern string) bool {
+	return rt.s.Unhandle(joinPattern(rt.prefix, pattern))
+}
+
+// joinPattern insert prefix into the path part of pattern, pattern is [METHOD ]/path.
+func joinPattern(prefix string, pattern string) string {
+	if prefix == "" {
+		return pattern
+	}
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		return joinPath(prefix, pattern)
+	}
+	return method + " " + joinPath(prefix, strings.TrimLeft(path, " "))
+}
+
+func joinPath(prefix string, path string) string {
+	if prefix == "" {
+		return path
+	}
+	if path == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,77 @@
+package httpd
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinPattern(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		pattern string
+		want    string
+	}{
+		{prefix: "", pattern: "GET /users", want: "GET /users"},
+		{prefix: "/api", pattern: "/users", want: "/api/users"},
+		{prefix: "/api/", pattern: "GET /users", want: "GET /api/users"},
+		{prefix: "/api", pattern: "GET  /users/{id}", want: "GET /api/users/{id}"},
+		{prefix: "/api", pattern: "/", want: "/api/"},
+		{prefix: "/api", pattern: "", want: "/api"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, joinPattern(tt.prefix, tt.pattern), tt.prefix+" "+tt.pattern)
+	}
+}
+
+func TestGroupMiddlewares(t *testing.T) {
+	trace := func(name string) MiddlewareFunc {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	path := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}
+
+	cfg := NewCfg()
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		s.RegisterMiddleware(trace("server"))
+		s.HandleFunc("GET /plain", path)
+
+		api := s.Group("/api", trace("api"))
+		api.HandleFunc("GET /users", path)
+		v1 := api.Group("/v1", trace("v1"))
+		v1.Use(trace("v1-use"))
+		v1.HandleFunc("GET /users/{id}", path)
+		// middlewares of child group do not leak into the parent
+		api.HandleFunc("GET /items", path)
+	})
+
+	tests := []struct {
+		path  string
+		trace string
+	}{
+		{path: "/plain", trace: "server"},
+		{path: "/api/users", trace: "server,api"},
+		{path: "/api/v1/users/1", trace: "server,api,v1,v1-use"},
+		{path: "/api/items", trace: "server,api"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(base + tt.path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, tt.path)
+		require.Equal(t, tt.path, string(body))
+		require.Equal(t, tt.trace, strings.Join(resp.Header.Values("X-Trace"), ","), tt.path)
+	}
+}
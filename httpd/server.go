@@ -0,0 +1,528 @@
+package httpd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+const DefaultServerName = "default"
+
+var ErrServerStopped = errors.New("server stopped")
+
+type MiddlewareFunc func(http.Handler) http.Handler
+
+// Server is a named http server instance managed by Httpd, each has its own Cfg, mux and middlewares.
+type Server struct {
+	h    *Httpd
+	name string
+	st   atomic.Pointer[serverState]
+
+	tlsCfg       *tls.Config
+	certReloader *certReloader
+	mux          atomic.Pointer[http.ServeMux]
+	middlewares  []MiddlewareFunc
+
+	mu     sync.RWMutex
+	routes []*route
+
+	wsConns wsConns
+
+	// srvMu guards http server and listeners which are replaced on reload
+	srvMu       sync.Mutex
+	s           *http.Server
+	connTracker *connTracker
+	listeners   map[string][]*sharedListener
+	serveErrCh  chan error
+	stopCh      chan struct{}
+	// draining is previous http servers replaced by reload which are finishing in-flight requests
+	draining map[*http.Server]*connTracker
+	// stopped is set once stop called, the server can not be started or reloaded after that
+	stopped  bool
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// serverState is built from Cfg, it is replaced as a whole when reloading.
+type serverState struct {
+	cfg          *Cfg
+	auth         *auth
+	admission    *admission
+	compressOpts *CompressOptions
+	cors         http.Handler
+	// done is closed when the state is replaced or server stopped
+	done chan struct{}
+}
+
+func newServer(h *Httpd, name string) *Server {
+	s := &Server{
+		h:    h,
+		name: name,
+	}
+	s.mux.Store(http.NewServeMux())
+	s.RegisterMiddleware(s.logAndRecoverMiddleware, s.admissionMiddleware, s.routeCfgMiddleware, s.compressMiddleware)
+	return s
+}
+
+func newHTTPServer(cfg *Cfg, ct *connTracker) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ConnState:         ct.onStateChange,
+	}
+}
+
+func (s *Server) newState(cfg *Cfg) (*serverState, error) {
+	err := cfg.validate()
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid cfg")
+	}
+
+	st := &serverState{
+		cfg:  cfg,
+		done: make(chan struct{}),
+	}
+
+	st.auth, err = newAuth(cfg)
+	if err != nil {
+		return nil, errs.Wrap(err, "build auth fail")
+	}
+	if cfg.RateLimit > 0 || cfg.MaxInFlight > 0 {
+		st.admission = newAdmission(cfg)
+	}
+	if cfg.EnableCompression {
+		st.compressOpts = compressOptionsFromCfg(cfg)
+	}
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		st.cors = CORS(corsOptionsFromCfg(cfg))(http.HandlerFunc(s.serveMux))
+	}
+	return st, nil
+}
+
+func (s *Server) Name() string {
+	return s.name
+}
+
+// Cfg returns the cfg in use, nil if the server is not configured.
+func (s *Server) Cfg() *Cfg {
+	st := s.st.Load()
+	if st == nil {
+		return nil
+	}
+	return st.cfg
+}
+
+func (s *Server) init(cfg *Cfg) error {
+	st, err := s.newState(cfg)
+	if err != nil {
+		return err
+	}
+	s.st.Store(st)
+
+	for pattern, handler := range s.builtinHandlers(cfg) {
+		err = s.addRoute(pattern, handler, nil, nil)
+		if err != nil {
+			return errs.Wrapf(err, "register built-in handler %s fail", pattern)
+		}
+	}
+
+	if tlsEnabled(cfg) {
+		tlsCfg, err := buildTLSConfig(cfg)
+		if err != nil {
+			return errs.Wrap(err, "build tls config fail")
+		}
+		s.certReloader, err = newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return errs.Wrap(err, "load tls certificate fail")
+		}
+		tlsCfg.GetCertificate = s.certReloader.GetCertificate
+		s.tlsCfg = tlsCfg
+	}
+	return nil
+}
+
+func (s *Server) start() error {
+	st := s.st.Load()
+	listeners, err := s.listen(st.cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	if s.tlsCfg != nil && !st.cfg.DisableTLSCertReload {
+		err = s.certReloader.watch(s.h.Stopping(), s.onCertReload)
+		if err != nil {
+			closeSharedListeners(listeners)
+			return errs.Wrap(err, "watch tls certificate fail")
+		}
+	}
+
+	s.srvMu.Lock()
+	if s.stopped {
+		// stopped while binding, e.g. another server failed to start
+		s.srvMu.Unlock()
+		closeSharedListeners(listeners)
+		return nil
+	}
+	srv, ct, err := s.buildHTTPServer(st)
+	if err != nil {
+		s.srvMu.Unlock()
+		closeSharedListeners(listeners)
+		return err
+	}
+	s.listeners = listeners
+	s.serveErrCh = make(chan error, 1)
+	s.stopCh = make(chan struct{})
+	s.serve(srv, ct)
+	serveErrCh, stopCh := s.serveErrCh, s.stopCh
+	s.srvMu.Unlock()
+	s.runState(st)
+
+	select {
+	case err = <-serveErrCh:
+		// one listener broken, close the others
+		s.srvMu.Lock()
+		closeSharedListeners(s.listeners)
+		if s.s != nil {
+			_ = s.s.Close()
+		}
+		s.srvMu.Unlock()
+		return err
+	case <-stopCh:
+		return nil
+	}
+}
+
+// listen binds addresses in cfg, listeners in reuse are reused if the address is not changed.
+func (s *Server) listen(cfg *Cfg, reuse map[string][]*sharedListener) (map[string][]*sharedListener, error) {
+	listeners := make(map[string][]*sharedListener)
+	for _, addr := range listenAddrs(cfg) {
+		if _, exists := listeners[addr]; exists {
+			continue
+		}
+		if sls, exists := reuse[addr]; exists {
+			listeners[addr] = sls
+			continue
+		}
+
+		ls, err := listen(cfg, addr)
+		if err != nil {
+			for a, sls := range listeners {
+				if _, reused := reuse[a]; !reused {
+					closeSharedListeners(map[string][]*sharedListener{a: sls})
+				}
+			}
+			return nil, errs.Wrapf(err, "listen fail: %s", addr)
+		}
+		for _, l := range ls {
+			s.h.Info("listening", "server", s.name, "network", l.Addr().Network(), "addr", l.Addr().String(), "tls", s.tlsCfg != nil)
+			listeners[addr] = append(listeners[addr], newSharedListener(l))
+		}
+	}
+	if len(listeners) == 0 {
+		return nil, errs.New("no listen address")
+	}
+	return listeners, nil
+}
+
+func closeSharedListeners(listeners map[string][]*sharedListener) {
+	for _, sls := range listeners {
+		for _, sl := range sls {
+			_ = sl.Close()
+		}
+	}
+}
+
+// buildHTTPServer builds a http server from st without serving.
+func (s *Server) buildHTTPServer(st *serverState) (*http.Server, *connTracker, error) {
+	ct := newConnTracker()
+	srv := newHTTPServer(st.cfg, ct)
+	srv.Handler = s.rootHandler()
+	if s.tlsCfg != nil {
+		srv.TLSConfig = s.tlsCfg
+	}
+	err := configureHTTP2(srv, st.cfg)
+	if err != nil {
+		return nil, nil, errs.Wrap(err, "configure http2 fail")
+	}
+	return srv, ct, nil
+}
+
+// serve starts srv on s.listeners and replaces the serving one, must be called with srvMu held.
+func (s *Server) serve(srv *http.Server, ct *connTracker) {
+	for _, sls := range s.listeners {
+		for _, sl := range sls {
+			go s.serveOn(srv, sl.view())
+		}
+	}
+	s.s, s.connTracker = srv, ct
+}
+
+func (s *Server) serveOn(srv *http.Server, l net.Listener) {
+	var err error
+	if s.tlsCfg != nil {
+		err = srv.ServeTLS(l, "", "")
+	} else {
+		err = srv.Serve(l)
+	}
+	if err == nil || errors.Is(err, http.ErrServerClosed) || errors.Is(err, net.ErrClosed) {
+		return
+	}
+	select {
+	case s.serveErrCh <- err:
+	default:
+	}
+}
+
+// runState starts background jobs of st.
+func (s *Server) runState(st *serverState) {
+	if st.admission != nil && st.admission.rateLimitEnabled() {
+		go st.admission.cleanLoop(st.done)
+	}
+}
+
+// rootHandler wraps mux with handlers which must run before routing, e.g. CORS preflight.
+func (s *Server) rootHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cors := s.st.Load().cors; cors != nil {
+			cors.ServeHTTP(w, r)
+			return
+		}
+		s.serveMux(w, r)
+	})
+}
+
+func (s *Server) serveMux(w http.ResponseWriter, r *http.Request) {
+	s.mux.Load().ServeHTTP(w, r)
+}
+
+// builtinHandlers returns handlers of built-in endpoints enabled in cfg by pattern,
+// OpenAPI document and route list are protected by Auth, health checks are not since they are requested by probes.
+func (s *Server) builtinHandlers(cfg *Cfg) map[string]http.Handler {
+	handlers := make(map[string]http.Handler)
+	for pattern, handler := range map[string]http.Handler{
+		cfg.OpenAPIPath:   s.Auth()(http.HandlerFunc(s.openAPIHandler)),
+		cfg.RoutesPath:    s.Auth()(http.HandlerFunc(s.routesHandler)),
+		cfg.LivenessPath:  http.HandlerFunc(s.livenessHandler),
+		cfg.ReadinessPath: http.HandlerFunc(s.readinessHandler),
+	} {
+		if pattern != "" {
+			handlers[pattern] = handler
+		}
+	}
+	return handlers
+}
+
+// stop shuts down the server, it is safe to call more than once and before start.
+func (s *Server) stop() error {
+	s.stopOnce.Do(func() {
+		s.stopErr = s.doStop()
+	})
+	return s.stopErr
+}
+
+func (s *Server) doStop() error {
+	s.srvMu.Lock()
+	s.stopped = true
+	srv, ct, listeners, stopCh, draining := s.s, s.connTracker, s.listeners, s.stopCh, s.draining
+	s.s, s.connTracker, s.listeners, s.draining = nil, nil, nil, nil
+	s.srvMu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	// stop accepting before draining
+	closeSharedListeners(listeners)
+
+	// hijacked connections are not closed by http server
+	if n := s.wsConns.closeAll(); n > 0 {
+		s.h.Info("websocket connections closed", "server", s.name, "count", n)
+	}
+
+	st := s.st.Load()
+	defer func() {
+		close(st.done)
+		close(stopCh)
+	}()
+
+	// http servers replaced by reload are still draining, stop them in the same timeout
+	wg := sync.WaitGroup{}
+	for d, dct := range draining {
+		wg.Add(1)
+		go func(d *http.Server, dct *connTracker) {
+			defer wg.Done()
+			_ = s.shutdown(d, dct, st.cfg.ShutdownTimeout)
+		}(d, dct)
+	}
+	defer wg.Wait()
+	return s.shutdown(srv, ct, st.cfg.ShutdownTimeout)
+}
+
+// shutdown waits in-flight requests of srv to finish in timeout, then close it forcibly.
+func (s *Server) shutdown(srv *http.Server, ct *connTracker, timeout time.Duration) error {
+	if timeout <= 0 {
+		return srv.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return errs.Wrap(err, "shutdown http server fail")
+	}
+
+	s.h.Warn("shutdown timeout, force close", "server", s.name, "timeout", timeout, "aborted_conns", ct.activeCount())
+	return srv.Close()
+}
+
+func (s *Server) onCertReload(err error) {
+	if err != nil {
+		s.h.Error("reload tls certificate fail, keep using the previous one", err, "server", s.name)
+		return
+	}
+	st := s.st.Load()
+	s.h.Info("tls certificate reloaded", "server", s.name, "cert", st.cfg.TLSCertFile, "key", st.cfg.TLSKeyFile)
+}
+
+// RegisterMiddleware must called before Handle func below.
+func (s *Server) RegisterMiddleware(mf ...MiddlewareFunc) {
+	s.middlewares = append(s.middlewares, mf...)
+}
+
+func (s *Server) buildHandlerChain(next http.Handler, mfs []MiddlewareFunc) http.Handler {
+	handler := next
+	for i := len(mfs) - 1; i >= 0; i-- {
+		handler = mfs[i](handler)
+	}
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		handler = s.middlewares[i](handler)
+	}
+	return handler
+}
+
+// handle panics if pattern is invalid or conflicts with a registered one, like http.ServeMux.
+func (s *Server) handle(pattern string, handler http.Handler, mfs []MiddlewareFunc, rc *RouteCfg) {
+	err := s.addRoute(pattern, handler, mfs, rc)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *Server) addRoute(pattern string, handler http.Handler, mfs []MiddlewareFunc, rc *RouteCfg) error {
+	rt := newRoute(s, pattern, handler, rc)
+	chain := s.buildHandlerChain(rateLimitPendingMiddleware(handler), mfs)
+	rt.chain = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chain.ServeHTTP(w, r.WithContext(withRoute(r.Context(), rt)))
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	routes := make([]*route, len(s.routes), len(s.routes)+1)
+	copy(routes, s.routes)
+	routes = append(routes, rt)
+	return s.swapRoutes(routes)
+}
+
+// Unhandle removes the route registered with exactly the same pattern, it is safe to call after Start,
+// requests being served by the route are not affected. It returns false if pattern is not registered.
+func (s *Server) Unhandle(pattern string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed *route
+	routes := make([]*route, 0, len(s.routes))
+	for _, rt := range s.routes {
+		if rt.pattern != pattern {
+			routes = append(routes, rt)
+		} else {
+			removed = rt
+		}
+	}
+	if removed == nil {
+		return false
+	}
+	_ = s.swapRoutes(routes)
+	if c, ok := removed.handler.(interface{ close() }); ok {
+		c.close()
+	}
+	return true
+}
+
+// swapRoutes builds a new mux from routes and replaces the serving one, the serving one is never modified
+// so routes can be added or removed safely while serving, must be called with mu held.
+func (s *Server) swapRoutes(routes []*route) (err error) {
+	mux := http.NewServeMux()
+	defer func() {
+		if e := recover(); e != nil {
+			err = errs.Errorf("%v", e)
+		}
+	}()
+	for _, rt := range routes {
+		mux.Handle(rt.pattern, rt.chain)
+	}
+
+	s.routes = routes
+	s.mux.Store(mux)
+	return nil
+}
+
+// Group returns a Router which registers handlers with path prefix and its own middlewares.
+func (s *Server) Group(prefix string, mf ...MiddlewareFunc) *Router {
+	return &Router{
+		s:           s,
+		prefix:      prefix,
+		middlewares: mf,
+	}
+}
+
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.handle(pattern, handler, nil, nil)
+}
+
+func (s *Server) HandleFunc(pattern string, handler http.HandlerFunc) {
+	s.handle(pattern, handler, nil, nil)
+}
+
+func (s *Server) HandleRaw(pattern string, handler RawHandler) {
+	s.handle(pattern, handler, nil, nil)
+}
+
+func (s *Server) HandleAPI(pattern string, handler APIHandler) {
+	s.handle(pattern, handler, nil, nil)
+}
+
+func (s *Server) HandleREST(pattern string, handler RESTHandler) {
+	s.handle(pattern, handler, nil, nil)
+}
+
+func (s *Server) HandleSSE(pattern string, handler SSEHandler) {
+	s.handle(pattern, handler, nil, nil)
+}
+
+func (s *Server) HandleWS(pattern string, handler WSHandler) {
+	s.handle(pattern, handler, nil, nil)
+}
+
+// HandleProxy proxies requests matching pattern to targets, e.g. http://10.0.0.1:8080, it returns error if targets or opts is invalid.
+func (s *Server) HandleProxy(pattern string, targets []string, opts *ProxyOptions) error {
+	return s.handleProxy(pattern, targets, opts, nil, nil)
+}
+
+// HandleStatic serves files of fsys, e.g. embed.FS or os.DirFS, use fs.Sub to serve a subdirectory of embed.FS.
+func (s *Server) HandleStatic(pattern string, fsys fs.FS, opts *StaticOptions) {
+	s.handle(pattern, newStaticHandler(fsys, opts), nil, nil)
+}
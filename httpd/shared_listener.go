@@ -0,0 +1,105 @@
+package httpd
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// sharedListener accepts connections for http servers serving on its views, so a listener can be handed over
+// from an old http server to a new one without closing it, e.g. when reloading cfg.
+type sharedListener struct {
+	net.Listener
+
+	connCh    chan net.Conn
+	errCh     chan error
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func newSharedListener(l net.Listener) *sharedListener {
+	sl := &sharedListener{
+		Listener: l,
+		connCh:   make(chan net.Conn),
+		errCh:    make(chan error),
+		closing:  make(chan struct{}),
+	}
+	go sl.acceptLoop()
+	return sl
+}
+
+func (sl *sharedListener) acceptLoop() {
+	for {
+		conn, err := sl.Listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// let the http server decide to retry or not
+			select {
+			case sl.errCh <- err:
+				continue
+			case <-sl.closing:
+				return
+			}
+		}
+
+		select {
+		case sl.connCh <- conn:
+		case <-sl.closing:
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+func (sl *sharedListener) Close() error {
+	var err error
+	sl.closeOnce.Do(func() {
+		close(sl.closing)
+		err = sl.Listener.Close()
+	})
+	return err
+}
+
+// view returns a listener accepting connections from sl, closing it does not close sl.
+func (sl *sharedListener) view() net.Listener {
+	return &listenerView{
+		sl:     sl,
+		closed: make(chan struct{}),
+	}
+}
+
+type listenerView struct {
+	sl        *sharedListener
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (v *listenerView) Accept() (net.Conn, error) {
+	select {
+	case <-v.closed:
+		return nil, net.ErrClosed
+	default:
+	}
+
+	select {
+	case conn := <-v.sl.connCh:
+		return conn, nil
+	case err := <-v.sl.errCh:
+		return nil, err
+	case <-v.closed:
+		return nil, net.ErrClosed
+	case <-v.sl.closing:
+		return nil, net.ErrClosed
+	}
+}
+
+func (v *listenerView) Close() error {
+	v.closeOnce.Do(func() { close(v.closed) })
+	return nil
+}
+
+func (v *listenerView) Addr() net.Addr {
+	return v.sl.Addr()
+}
@@ -0,0 +1,233 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/donkeywon/golib/util/jsonu"
+)
+
+const (
+	ContentTypeEventStream = "text/event-stream"
+
+	HeaderLastEventID = "Last-Event-ID"
+	// queryLastEventID is used by EventSource polyfills which can not set headers
+	queryLastEventID = "lastEventId"
+)
+
+var (
+	ErrStreamClosed = errors.New("stream closed")
+	ErrInvalidEvent = errors.New("invalid event")
+)
+
+// Event is a server-sent event, fields are omitted if empty.
+// ID and Event must not contain line breaks, Data can be multiple lines.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry tells client how long to wait before reconnecting
+	Retry time.Duration
+}
+
+func (ev *Event) marshal() ([]byte, error) {
+	// line breaks in id or event would start new fields
+	if strings.ContainsAny(ev.ID, "\r\n") || strings.ContainsAny(ev.Event, "\r\n") {
+		return nil, errors.Join(ErrInvalidEvent, errors.New("id and event must not contain line breaks"))
+	}
+
+	var sb strings.Builder
+	if ev.ID != "" {
+		sb.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		sb.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	// CRLF, CR and LF are all line breaks in event stream
+	data := strings.ReplaceAll(strings.ReplaceAll(ev.Data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	return []byte(sb.String()), nil
+}
+
+// EventSink sends events to client of a SSE stream, it is safe for concurrent use.
+type EventSink struct {
+	ctx          context.Context
+	w            http.ResponseWriter
+	ctl          *http.ResponseController
+	lastEventID  string
+	writeTimeout time.Duration
+
+	mu  sync.Mutex
+	err error
+}
+
+// Send writes ev and flushes it to client, it returns error if client disconnected, server is stopping
+// or the handler has returned, ErrInvalidEvent if ev is invalid.
+func (es *EventSink) Send(ev *Event) error {
+	data, err := ev.marshal()
+	if err != nil {
+		return err
+	}
+	return es.write(data)
+}
+
+// SendJSON sends v encoded in json as data of an event named event.
+func (es *EventSink) SendJSON(event string, v interface{}) error {
+	data, err := jsonu.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return es.Send(&Event{Event: event, Data: string(data)})
+}
+
+// LastEventID returns the id of last event client received before reconnecting, empty if first connected.
+func (es *EventSink) LastEventID() string {
+	return es.lastEventID
+}
+
+// Done is closed when client disconnected or server is stopping.
+func (es *EventSink) Done() <-chan struct{} {
+	return es.ctx.Done()
+}
+
+func (es *EventSink) Context() context.Context {
+	return es.ctx
+}
+
+func (es *EventSink) comment(text string) error {
+	return es.write([]byte(": " + text + "\n\n"))
+}
+
+// close rejects writes after the handler returned, the response writer must not be used after that.
+func (es *EventSink) close() {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.err == nil {
+		es.err = ErrStreamClosed
+	}
+}
+
+func (es *EventSink) write(data []byte) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if es.err != nil {
+		return es.err
+	}
+	if es.ctx.Err() != nil {
+		es.err = ErrStreamClosed
+		return es.err
+	}
+
+	if es.writeTimeout > 0 {
+		_ = es.ctl.SetWriteDeadline(time.Now().Add(es.writeTimeout))
+	}
+	_, err := es.w.Write(data)
+	if err == nil {
+		err = es.ctl.Flush()
+	}
+	if err != nil {
+		es.err = errors.Join(ErrStreamClosed, err)
+	}
+	return es.err
+}
+
+// SSEHandler streams events to client until it returns, the stream is closed after that.
+type SSEHandler func(r *http.Request, sink *EventSink) error
+
+func (sh SSEHandler) Handle(r *http.Request, sink *EventSink) error {
+	return sh(r, sink)
+}
+
+// ServeHTTP sends keepalive comments every SSEKeepAliveInterval of the server, each write must finish in WriteTimeout
+// of the server instead of the whole response. The stream is compressed if text/event-stream matches
+// CompressContentTypes, each event is flushed through the encoder. Sending after handler returned fails with
+// ErrStreamClosed, so goroutines started by handler never write to the finished response.
+func (sh SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		keepAlive    time.Duration
+		writeTimeout time.Duration
+		stopping     <-chan struct{}
+	)
+	if rt := routeFromCtx(r.Context()); rt != nil {
+		cfg := rt.s.Cfg()
+		keepAlive, writeTimeout, stopping = cfg.SSEKeepAliveInterval, cfg.WriteTimeout, rt.s.h.Stopping()
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	keepAliveWg := sync.WaitGroup{}
+	if stopping != nil {
+		go func() {
+			select {
+			case <-stopping:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	lastEventID := r.Header.Get(HeaderLastEventID)
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get(queryLastEventID)
+	}
+	sink := &EventSink{
+		ctx:          ctx,
+		w:            w,
+		ctl:          http.NewResponseController(w),
+		lastEventID:  lastEventID,
+		writeTimeout: writeTimeout,
+	}
+
+	// the whole stream is not limited by write timeout of server
+	_ = sink.ctl.SetWriteDeadline(time.Time{})
+	h := w.Header()
+	h.Set(headerContentType, ContentTypeEventStream)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	defer func() {
+		cancel()
+		sink.close()
+		keepAliveWg.Wait()
+	}()
+	err := sink.comment("ok")
+	if err != nil {
+		return
+	}
+
+	if keepAlive > 0 {
+		keepAliveWg.Add(1)
+		go func() {
+			defer keepAliveWg.Done()
+			t := time.NewTicker(keepAlive)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					if sink.comment("keepalive") != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	err = sh(r.WithContext(ctx), sink)
+	if err != nil && !errors.Is(err, ErrStreamClosed) && !errors.Is(err, context.Canceled) {
+		LoggerFromCtx(r.Context()).Error("sse stream fail", err)
+	}
+}
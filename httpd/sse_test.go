@@ -0,0 +1,99 @@
+package httpd
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventMarshal(t *testing.T) {
+	data, err := (&Event{ID: "1", Event: "msg", Data: "a\r\nb\rc\nd", Retry: time.Second}).marshal()
+	require.NoError(t, err)
+	require.Equal(t, "id: 1\nevent: msg\nretry: 1000\ndata: a\ndata: b\ndata: c\ndata: d\n\n", string(data))
+
+	_, err = (&Event{ID: "1\ndata: injected"}).marshal()
+	require.ErrorIs(t, err, ErrInvalidEvent)
+	_, err = (&Event{Event: "msg\rdata: injected"}).marshal()
+	require.ErrorIs(t, err, ErrInvalidEvent)
+}
+
+// readEventLines reads lines of event stream until a line has prefix.
+func readEventLines(t *testing.T, br *bufio.Reader, prefix string) []string {
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+		if strings.HasPrefix(line, prefix) {
+			return lines
+		}
+	}
+}
+
+func TestSSE(t *testing.T) {
+	cfg := NewCfg()
+	cfg.SSEKeepAliveInterval = 20 * time.Millisecond
+
+	handlerDone := make(chan error, 1)
+	sinkCh := make(chan *EventSink, 1)
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		s.HandleSSE("GET /resume", func(_ *http.Request, sink *EventSink) error {
+			return sink.Send(&Event{ID: "2", Data: "after " + sink.LastEventID()})
+		})
+		s.HandleSSE("GET /returned", func(_ *http.Request, sink *EventSink) error {
+			sinkCh <- sink
+			return nil
+		})
+		s.HandleSSE("GET /stream", func(_ *http.Request, sink *EventSink) error {
+			<-sink.Done()
+			err := sink.Send(&Event{Data: "gone"})
+			handlerDone <- err
+			return err
+		})
+	})
+
+	for _, c := range []struct {
+		path   string
+		header http.Header
+	}{
+		{path: "/resume", header: http.Header{HeaderLastEventID: {"1"}}},
+		{path: "/resume?lastEventId=1"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, base+c.path, nil)
+		require.NoError(t, err)
+		req.Header = c.header
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, ContentTypeEventStream, resp.Header.Get(headerContentType))
+		lines := readEventLines(t, bufio.NewReader(resp.Body), "data:")
+		_ = resp.Body.Close()
+		require.Equal(t, "data: after 1", lines[len(lines)-1])
+	}
+
+	// sending after handler returned never writes to the finished response
+	resp, err := http.Get(base + "/returned")
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.ErrorIs(t, (<-sinkCh).Send(&Event{Data: "late"}), ErrStreamClosed)
+
+	resp, err = http.Get(base + "/stream")
+	require.NoError(t, err)
+	readEventLines(t, bufio.NewReader(resp.Body), ": keepalive")
+
+	// client disconnect cancels the stream
+	_ = resp.Body.Close()
+	select {
+	case err = <-handlerDone:
+		require.ErrorIs(t, err, ErrStreamClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream is not closed after client disconnected")
+	}
+}
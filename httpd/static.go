@@ -0,0 +1,269 @@
+package httpd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	encodingBrotli = "br"
+
+	headerETag         = "ETag"
+	headerCacheControl = "Cache-Control"
+
+	defaultStaticIndex = "index.html"
+)
+
+// precompressedVariants in order of preference when client accepts several with same q value.
+var precompressedVariants = []struct {
+	encoding string
+	ext      string
+}{
+	{encoding: encodingBrotli, ext: ".br"},
+	{encoding: EncodingGzip, ext: ".gz"},
+}
+
+type StaticOptions struct {
+	// StripPrefix is removed from request path to get the file name, e.g. /ui for pattern /ui/.
+	StripPrefix string
+	// Index is served for requests of a directory, default index.html.
+	Index string
+	// SPAFallback serves the Index of root for paths without extension which are not found,
+	// so that routes of single page application work after reloading page.
+	SPAFallback bool
+	// Precompressed serves name.br or name.gz instead of name if it exists and client accepts the encoding.
+	Precompressed bool
+	// DirListing lists files of a directory without Index, disabled by default.
+	DirListing bool
+	// CacheControl is set to responses of files, e.g. public, max-age=86400,
+	// Index is always responded with no-cache so that new versions are seen after deploying.
+	CacheControl string
+}
+
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// staticHandler serves files of fsys with ETag and Last-Modified, conditional and range requests are handled by http.ServeContent.
+type staticHandler struct {
+	fsys  fs.FS
+	opts  *StaticOptions
+	etags sync.Map
+}
+
+func newStaticHandler(fsys fs.FS, opts *StaticOptions) *staticHandler {
+	o := StaticOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Index == "" {
+		o.Index = defaultStaticIndex
+	}
+	return &staticHandler{fsys: fsys, opts: &o}
+}
+
+func (sh *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := sh.fileName(r.URL.Path)
+	fi, err := fs.Stat(sh.fsys, name)
+	if err == nil && fi.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			u := *r.URL
+			u.Path += "/"
+			http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+		if sh.serveIndex(w, r, name) {
+			return
+		}
+		if sh.opts.DirListing {
+			sh.serveDir(w, r, name)
+			return
+		}
+		err = fs.ErrNotExist
+	}
+
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			sh.fail(w, r, name, err)
+			return
+		}
+		if sh.opts.SPAFallback && path.Ext(name) == "" && sh.serveIndex(w, r, ".") {
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	sh.serveFile(w, r, name, fi, sh.opts.CacheControl)
+}
+
+// fileName returns the name in fsys of url path, "." is the root.
+func (sh *staticHandler) fileName(urlPath string) string {
+	p := path.Clean("/" + strings.TrimPrefix(urlPath, sh.opts.StripPrefix))
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// serveIndex serves Index in dir, returns false if not exists.
+func (sh *staticHandler) serveIndex(w http.ResponseWriter, r *http.Request, dir string) bool {
+	name := path.Join(dir, sh.opts.Index)
+	fi, err := fs.Stat(sh.fsys, name)
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	sh.serveFile(w, r, name, fi, "no-cache")
+	return true
+}
+
+func (sh *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string, fi fs.FileInfo, cacheControl string) {
+	h := w.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+
+	servedName, servedFi := name, fi
+	if sh.opts.Precompressed {
+		h.Add(headerVary, headerAcceptEncoding)
+		if enc, vName, vFi := sh.precompressed(r, name); vName != "" {
+			servedName, servedFi = vName, vFi
+			h.Set(headerContentEncoding, enc)
+			if contentType == "" {
+				// content type can not be sniffed from compressed content
+				contentType = "application/octet-stream"
+			}
+		}
+	}
+
+	f, err := sh.fsys.Open(servedName)
+	if err != nil {
+		sh.fail(w, r, servedName, err)
+		return
+	}
+	defer f.Close()
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			sh.fail(w, r, servedName, err)
+			return
+		}
+		rs = bytes.NewReader(data)
+	}
+	etag, err := sh.etag(servedName, servedFi, rs)
+	if err != nil {
+		sh.fail(w, r, servedName, err)
+		return
+	}
+
+	if contentType != "" {
+		h.Set(headerContentType, contentType)
+	}
+	h.Set(headerETag, etag)
+	if cacheControl != "" {
+		h.Set(headerCacheControl, cacheControl)
+	}
+	http.ServeContent(w, r, name, servedFi.ModTime(), rs)
+}
+
+// precompressed returns the encoding and the precompressed variant of name with highest q value accepted by client,
+// empty if none.
+func (sh *staticHandler) precompressed(r *http.Request, name string) (string, string, fs.FileInfo) {
+	ae := r.Header.Get(headerAcceptEncoding)
+	if ae == "" {
+		return "", "", nil
+	}
+
+	qs := parseAcceptEncoding(ae)
+	var (
+		bestEnc, bestName string
+		bestFi            fs.FileInfo
+		bestQ             float64
+	)
+	for _, v := range precompressedVariants {
+		q, exists := qs[v.encoding]
+		if !exists {
+			q, exists = qs["*"]
+		}
+		if !exists || q <= bestQ {
+			continue
+		}
+		fi, err := fs.Stat(sh.fsys, name+v.ext)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		bestEnc, bestName, bestFi, bestQ = v.encoding, name+v.ext, fi, q
+	}
+	return bestEnc, bestName, bestFi
+}
+
+// etag returns strong ETag of file content, it is cached until size or modification time of file changed.
+func (sh *staticHandler) etag(name string, fi fs.FileInfo, rs io.ReadSeeker) (string, error) {
+	if v, ok := sh.etags.Load(name); ok {
+		e := v.(*etagEntry)
+		if e.size == fi.Size() && e.modTime.Equal(fi.ModTime()) {
+			return e.etag, nil
+		}
+	}
+
+	hash := sha256.New()
+	_, err := io.Copy(hash, rs)
+	if err != nil {
+		return "", err
+	}
+	_, err = rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	sh.etags.Store(name, &etagEntry{size: fi.Size(), modTime: fi.ModTime(), etag: etag})
+	return etag, nil
+}
+
+func (sh *staticHandler) serveDir(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(sh.fsys, name)
+	if err != nil {
+		sh.fail(w, r, name, err)
+		return
+	}
+
+	w.Header().Set(headerContentType, "text/html; charset=utf-8")
+	_, _ = fmt.Fprintf(w, "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		// url.URL escapes names with special characters, e.g. a:b
+		u := url.URL{Path: n}
+		_, _ = fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(n))
+	}
+	_, _ = fmt.Fprintf(w, "</pre>\n")
+}
+
+func (sh *staticHandler) fail(w http.ResponseWriter, r *http.Request, name string, err error) {
+	LoggerFromCtx(r.Context()).Error("serve static file fail", err, "name", name)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -0,0 +1,83 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func tlsEnabled(cfg *Cfg) bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+}
+
+func buildTLSConfig(cfg *Cfg) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.TLSMinVersion != "" {
+		v, exists := tlsVersions[cfg.TLSMinVersion]
+		if !exists {
+			return nil, errs.Errorf("unsupported tls version: %s", cfg.TLSMinVersion)
+		}
+		tlsCfg.MinVersion = v
+	}
+
+	if len(cfg.TLSCipherSuites) > 0 {
+		suites, err := parseCipherSuites(cfg.TLSCipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.CipherSuites = suites
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pool, err := loadCertPool(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	all := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		all[s.Name] = s.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, exists := all[strings.TrimSpace(name)]
+		if !exists {
+			return nil, errs.Errorf("unsupported or insecure tls cipher suite: %s", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.Wrapf(err, "read ca file fail: %s", path)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bs) {
+		return nil, errs.Errorf("no valid certificate found in ca file: %s", path)
+	}
+	return pool, nil
+}
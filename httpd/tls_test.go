@@ -0,0 +1,177 @@
+package httpd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns pem encoded cert and key signed by ca, valid for 127.0.0.1 as server and cn as client.
+func (ca *testCA) issue(t *testing.T, cn string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// writeServerCert writes a server cert issued by ca to dir and sets it to cfg.
+func writeServerCert(t *testing.T, ca *testCA, cfg *Cfg, dir string) {
+	certPEM, keyPEM := ca.issue(t, "server")
+	cfg.TLSCertFile = filepath.Join(dir, "tls.crt")
+	cfg.TLSKeyFile = filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(cfg.TLSCertFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(cfg.TLSKeyFile, keyPEM, 0o600))
+}
+
+func newTLSClient(t *testing.T, ca *testCA, clientCert *tls.Certificate, maxVersion uint16) *http.Client {
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(ca.pem))
+	tlsCfg := &tls.Config{RootCAs: pool, MaxVersion: maxVersion}
+	if clientCert != nil {
+		tlsCfg.Certificates = []tls.Certificate{*clientCert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	cfg := NewCfg()
+	cfg.TLSMinVersion = "1.3"
+	writeServerCert(t, ca, cfg, dir)
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /proto", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		})
+	})
+
+	resp, err := newTLSClient(t, ca, nil, 0).Get(base + "/proto")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotNil(t, resp.TLS)
+	require.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+
+	// versions below TLSMinVersion are rejected
+	_, err = newTLSClient(t, ca, nil, tls.VersionTLS12).Get(base + "/proto")
+	require.Error(t, err)
+
+	// plain http is not served
+	resp, err = http.Get("http://" + cfg.Addr + "/proto")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	dir := t.TempDir()
+
+	cfg := NewCfg()
+	cfg.TLSClientCAFile = filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(cfg.TLSClientCAFile, ca.pem, 0o600))
+	writeServerCert(t, ca, cfg, dir)
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleFunc("GET /whoami", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		})
+	})
+
+	clientCert := func(ca *testCA) *tls.Certificate {
+		certPEM, keyPEM := ca.issue(t, "client")
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+		return &cert
+	}
+
+	resp, err := newTLSClient(t, ca, clientCert(ca), 0).Get(base + "/whoami")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "client", string(body))
+
+	// clients without cert or with cert of unknown ca are rejected
+	for _, cert := range []*tls.Certificate{nil, clientCert(otherCA)} {
+		_, err = newTLSClient(t, ca, cert, 0).Get(base + "/whoami")
+		require.Error(t, err)
+	}
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	cfg := NewCfg()
+	cfg.TLSCipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}
+	tlsCfg, err := buildTLSConfig(cfg)
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, tlsCfg.CipherSuites)
+	require.Equal(t, tls.NoClientCert, tlsCfg.ClientAuth)
+
+	// insecure suites are not accepted
+	cfg.TLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+	_, err = buildTLSConfig(cfg)
+	require.Error(t, err)
+
+	cfg = NewCfg()
+	cfg.TLSClientCAFile = filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(cfg.TLSClientCAFile, []byte("invalid"), 0o600))
+	_, err = buildTLSConfig(cfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package httpd
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util"
+	"github.com/donkeywon/golib/util/httpu"
+	"github.com/donkeywon/golib/util/jsonu"
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	ContentTypeYAML          = "application/yaml"
+	ContentTypeXYAML         = "application/x-yaml"
+	ContentTypeTextYAML      = "text/yaml"
+	ContentTypeForm          = "application/x-www-form-urlencoded"
+	ContentTypeMultipartForm = "multipart/form-data"
+
+	defaultMultipartMemory = 32 << 20
+	// defaultTypedMaxBodyBytes limits body of typed handlers if MaxBodyBytes is not set for the route or server
+	defaultTypedMaxBodyBytes = 10 << 20
+)
+
+// TypedHandler decodes request into Req, validates it by util.V and responds Res in the Resp envelope.
+// Req is decoded from query for requests without body, from body by Content-Type otherwise,
+// fields with path tag are decoded from path wildcards, e.g. `path:"id"` for pattern /users/{id}.
+// Body is limited to 10MB unless MaxBodyBytes is set, responds 413 when exceeded.
+type TypedHandler[Req any, Res any] func(ctx context.Context, req *Req) (*Res, error)
+
+func (th TypedHandler[Req, Res]) Handle(ctx context.Context, req *Req) (*Res, error) {
+	return th(ctx, req)
+}
+
+func (th TypedHandler[Req, Res]) types() (reflect.Type, reflect.Type) {
+	return reflect.TypeOf((*Req)(nil)).Elem(), reflect.TypeOf((*Res)(nil)).Elem()
+}
+
+func (th TypedHandler[Req, Res]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rt := routeFromCtx(r.Context()); rt == nil || rt.s.routeCfgOf(rt).MaxBodyBytes == 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, defaultTypedMaxBodyBytes)
+	}
+
+	req := new(Req)
+	err := decodeRequest(r, req)
+	if err != nil {
+		RespErr(w, r, errs.Wrap(&badRequestError{err}, "decode request fail"))
+		return
+	}
+
+	if reflect.TypeOf(req).Elem().Kind() == reflect.Struct {
+		err = util.V.Struct(req)
+		if err != nil {
+			RespErr(w, r, errs.Wrap(&badRequestError{err}, "invalid request"))
+			return
+		}
+	}
+
+	resp, err := th(r.Context(), req)
+	if err != nil {
+		RespErr(w, r, err)
+		return
+	}
+	httpu.RespJSONOk(&Resp{Code: RespCodeOk, Data: resp}, w)
+}
+
+func decodeRequest(r *http.Request, v interface{}) error {
+	if !hasBody(r) {
+		err := decodeForm(r.URL.Query(), v)
+		if err != nil {
+			return err
+		}
+		return decodePathValues(r, v)
+	}
+
+	ct := httpu.ContentTypeJSON
+	if h := r.Header.Get(httpu.HeaderContentType); h != "" {
+		mt, _, err := mime.ParseMediaType(h)
+		if err != nil {
+			return errs.Wrap(err, "invalid content type")
+		}
+		ct = mt
+	}
+
+	switch ct {
+	case ContentTypeForm, ContentTypeMultipartForm:
+		var err error
+		if ct == ContentTypeForm {
+			// ParseMultipartForm drops the error of ParseForm, e.g. body too large
+			err = r.ParseForm()
+		} else {
+			err = r.ParseMultipartForm(defaultMultipartMemory)
+		}
+		if err != nil {
+			return errs.Wrap(err, "parse form fail")
+		}
+		err = decodeForm(r.Form, v)
+		if err != nil {
+			return err
+		}
+	case httpu.ContentTypeJSON, ContentTypeYAML, ContentTypeXYAML, ContentTypeTextYAML:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return errs.Wrap(err, "read body fail")
+		}
+		if ct == httpu.ContentTypeJSON {
+			err = jsonu.Unmarshal(body, v)
+		} else {
+			err = yaml.Unmarshal(body, v)
+		}
+		if err != nil {
+			return errs.Wrapf(err, "unmarshal %s body fail", ct)
+		}
+	default:
+		return errs.Errorf("unsupported content type: %s", ct)
+	}
+
+	return decodePathValues(r, v)
+}
+
+func hasBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+func HandleTyped[Req any, Res any](pattern string, handler TypedHandler[Req, Res]) {
+	_h.GetServer(DefaultServerName).Handle(pattern, handler)
+}
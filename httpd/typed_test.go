@@ -0,0 +1,119 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type typedTestReq struct {
+	ID      int           `path:"id"`
+	Name    string        `json:"name"    yaml:"name"    validate:"required"`
+	Tags    []string      `json:"tags"    yaml:"tags"    form:"tag"`
+	Enabled bool          `json:"enabled" yaml:"enabled"`
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	echo := TypedHandler[typedTestReq, typedTestReq](func(_ context.Context, r *typedTestReq) (*typedTestReq, error) {
+		return r, nil
+	})
+	base := startHttpd(t, NewCfg(), func(h *Httpd) {
+		h.GetServer(DefaultServerName).Handle("/users/{id}", echo)
+	})
+
+	want := typedTestReq{ID: 1, Name: "a", Tags: []string{"x", "y"}, Enabled: true, Timeout: time.Second}
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		ct     string
+		body   string
+		status int
+		code   RespCode
+	}{
+		{name: "query", method: http.MethodGet, query: "name=a&tag=x&tag=y&enabled=true&timeout=1s", status: http.StatusOK},
+		{name: "json", method: http.MethodPost, ct: "application/json", body: `{"name":"a","tags":["x","y"],"enabled":true,"timeout":1000000000}`, status: http.StatusOK},
+		{name: "json by default", method: http.MethodPut, body: `{"name":"a","tags":["x","y"],"enabled":true,"timeout":1000000000}`, status: http.StatusOK},
+		{name: "yaml", method: http.MethodPost, ct: ContentTypeYAML, body: "name: a\ntags: [x, y]\nenabled: true\ntimeout: 1s\n", status: http.StatusOK},
+		{name: "form", method: http.MethodPost, ct: ContentTypeForm + "; charset=utf-8", body: "name=a&tag=x&tag=y&enabled=true&timeout=1s", status: http.StatusOK},
+		{name: "validation", method: http.MethodGet, query: "tag=x", status: http.StatusBadRequest, code: RespCodeInvalidArgument},
+		{name: "invalid value", method: http.MethodGet, query: "name=a&enabled=maybe", status: http.StatusBadRequest, code: RespCodeInvalidArgument},
+		{name: "invalid json", method: http.MethodPost, ct: "application/json", body: `{"name":`, status: http.StatusBadRequest, code: RespCodeInvalidArgument},
+		{name: "unsupported content type", method: http.MethodPost, ct: "text/csv", body: "a", status: http.StatusBadRequest, code: RespCodeInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, base+"/users/1?"+tt.query, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			if tt.ct != "" {
+				req.Header.Set(headerContentType, tt.ct)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			got := &Resp{Data: &typedTestReq{}}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(got))
+			_ = resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+			require.Equal(t, tt.code, got.Code)
+			if tt.status == http.StatusOK {
+				require.Equal(t, &want, got.Data)
+			} else {
+				require.NotEmpty(t, got.Msg)
+			}
+		})
+	}
+
+	// invalid path value
+	resp, err := http.Get(base + "/users/abc?name=a")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestTypedHandlerBodyLimit(t *testing.T) {
+	type req struct {
+		Name string `json:"name" form:"name"`
+	}
+	type res struct {
+		Name string `json:"name"`
+	}
+	echo := TypedHandler[req, res](func(_ context.Context, r *req) (*res, error) {
+		return &res{Name: r.Name}, nil
+	})
+
+	cfg := NewCfg()
+	cfg.Routes = map[string]*RouteCfg{"/limited": {MaxBodyBytes: 16}}
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		s := h.GetServer(DefaultServerName)
+		s.Handle("/echo", echo)
+		s.Handle("/limited", echo)
+	})
+
+	large := `{"name":"` + strings.Repeat("a", defaultTypedMaxBodyBytes) + `"}`
+	tests := []struct {
+		name   string
+		path   string
+		ct     string
+		body   string
+		status int
+	}{
+		{name: "ok", path: "/echo", ct: "application/json", body: `{"name":"a"}`, status: http.StatusOK},
+		{name: "default limit json", path: "/echo", ct: "application/json", body: large, status: http.StatusRequestEntityTooLarge},
+		{name: "default limit form", path: "/echo", ct: ContentTypeForm, body: "name=" + strings.Repeat("a", defaultTypedMaxBodyBytes), status: http.StatusRequestEntityTooLarge},
+		{name: "route limit", path: "/limited", ct: "application/json", body: `{"name":"aaaaaaaaaaaaaaaa"}`, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(base+tt.path, tt.ct, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/donkeywon/golib/util/jsonu"
+	"github.com/gorilla/websocket"
+)
+
+// WSConn is a websocket connection, writes are safe for concurrent use, reads must be done by one goroutine.
+// Pongs are processed while reading, so the handler must keep reading until the connection closed,
+// otherwise it is closed after WSPongTimeout.
+type WSConn struct {
+	conn         *websocket.Conn
+	ctx          context.Context
+	cancel       context.CancelFunc
+	writeTimeout time.Duration
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+	closed    atomic.Bool
+}
+
+func newWSConn(ctx context.Context, conn *websocket.Conn, cfg *Cfg) *WSConn {
+	c := &WSConn{
+		conn:         conn,
+		writeTimeout: cfg.WSWriteTimeout,
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	if cfg.WSMaxMessageSize > 0 {
+		conn.SetReadLimit(cfg.WSMaxMessageSize)
+	}
+	if cfg.WSPongTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(cfg.WSPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(cfg.WSPongTimeout))
+		})
+	} else {
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+	_ = conn.SetWriteDeadline(time.Time{})
+	return c
+}
+
+// Context is canceled when the connection closed.
+func (c *WSConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *WSConn) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+func (c *WSConn) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// ReadMessage returns message type websocket.TextMessage or websocket.BinaryMessage and the message.
+func (c *WSConn) ReadMessage() (int, []byte, error) {
+	typ, data, err := c.conn.ReadMessage()
+	if err != nil {
+		c.cancel()
+	}
+	return typ, data, err
+}
+
+func (c *WSConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return jsonu.Unmarshal(data, v)
+}
+
+// WriteMessage writes a message of type websocket.TextMessage or websocket.BinaryMessage, it must finish in WSWriteTimeout.
+func (c *WSConn) WriteMessage(typ int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.writeTimeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	err := c.conn.WriteMessage(typ, data)
+	if err != nil {
+		c.cancel()
+	}
+	return err
+}
+
+func (c *WSConn) WriteJSON(v interface{}) error {
+	data, err := jsonu.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *WSConn) ping() error {
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.controlTimeout()))
+}
+
+func (c *WSConn) controlTimeout() time.Duration {
+	if c.writeTimeout > 0 {
+		return c.writeTimeout
+	}
+	return time.Second
+}
+
+// Close sends a close message with code and reason, e.g. websocket.CloseNormalClosure, then closes the connection.
+func (c *WSConn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		c.cancel()
+		_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(c.controlTimeout()))
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// WSHandler serves a websocket connection until it returns, the connection is closed after that.
+type WSHandler func(r *http.Request, conn *WSConn) error
+
+func (wh WSHandler) Handle(r *http.Request, conn *WSConn) error {
+	return wh(r, conn)
+}
+
+// ServeHTTP upgrades the request to websocket, origin must be the same as host or allowed by WSAllowedOrigins,
+// CORSAllowedOrigins is not used since cookies are sent with websocket handshakes of any origin.
+// Connections are closed with websocket.CloseGoingAway when the server stops.
+func (wh WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt := routeFromCtx(r.Context())
+	if rt == nil {
+		http.Error(w, "websocket handler must be registered by httpd", http.StatusInternalServerError)
+		return
+	}
+	s := rt.s
+	cfg := s.Cfg()
+
+	upgrader := &websocket.Upgrader{
+		HandshakeTimeout: cfg.WSWriteTimeout,
+		CheckOrigin: func(r *http.Request) bool {
+			return wsOriginAllowed(cfg.WSAllowedOrigins, r)
+		},
+	}
+
+	wsc, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader has responded with error
+		LoggerFromCtx(r.Context()).Warn("upgrade websocket fail", "err", err)
+		return
+	}
+
+	conn := newWSConn(r.Context(), wsc, cfg)
+	if !s.wsConns.add(conn) {
+		_ = conn.Close(websocket.CloseGoingAway, "server stopping")
+		return
+	}
+	defer func() {
+		s.wsConns.remove(conn)
+		_ = conn.Close(websocket.CloseNormalClosure, "")
+	}()
+
+	if cfg.WSPingInterval > 0 {
+		go func() {
+			t := time.NewTicker(cfg.WSPingInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-conn.Done():
+					return
+				case <-t.C:
+					if conn.ping() != nil {
+						conn.cancel()
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	err = wh(r.WithContext(conn.Context()), conn)
+	// errors caused by closing connection are ignored, e.g. server stopping
+	if err != nil && !conn.closed.Load() && !errors.Is(err, context.Canceled) && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+		LoggerFromCtx(r.Context()).Error("websocket handler fail", err)
+	}
+}
+
+// wsOriginAllowed reports whether the websocket handshake is from the same host or an allowed origin,
+// * is ignored so that any site can not open connections on behalf of logged in users.
+func wsOriginAllowed(allowed []string, r *http.Request) bool {
+	origin := r.Header.Get(headerOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	matched := matchOrigin(allowed, origin)
+	return matched != "" && matched != "*"
+}
+
+// wsConns tracks open websocket connections of a server which are hijacked and not closed by http server shutdown.
+type wsConns struct {
+	mu     sync.Mutex
+	conns  map[*WSConn]struct{}
+	closed bool
+}
+
+func (wc *wsConns) add(c *WSConn) bool {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.closed {
+		return false
+	}
+	if wc.conns == nil {
+		wc.conns = make(map[*WSConn]struct{})
+	}
+	wc.conns[c] = struct{}{}
+	return true
+}
+
+func (wc *wsConns) remove(c *WSConn) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	delete(wc.conns, c)
+}
+
+// closeAll closes all open connections and rejects new ones, returns the number of closed connections.
+func (wc *wsConns) closeAll() int {
+	wc.mu.Lock()
+	wc.closed = true
+	conns := make([]*WSConn, 0, len(wc.conns))
+	for c := range wc.conns {
+		conns = append(conns, c)
+	}
+	wc.mu.Unlock()
+
+	wg := sync.WaitGroup{}
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *WSConn) {
+			defer wg.Done()
+			_ = c.Close(websocket.CloseGoingAway, "server stopping")
+		}(c)
+	}
+	wg.Wait()
+	return len(conns)
+}
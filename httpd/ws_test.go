@@ -0,0 +1,78 @@
+package httpd
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/donkeywon/golib/runner"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWSOrigin(t *testing.T) {
+	cfg := NewCfg()
+	cfg.CORSAllowedOrigins = []string{"*"}
+	cfg.WSAllowedOrigins = []string{"*", "https://*.example.com"}
+	base := startHttpd(t, cfg, func(h *Httpd) {
+		h.GetServer(DefaultServerName).HandleWS("/ws", func(_ *http.Request, conn *WSConn) error {
+			_, _, err := conn.ReadMessage()
+			return err
+		})
+	})
+
+	tests := []struct {
+		name   string
+		origin string
+		ok     bool
+	}{
+		{name: "no origin", ok: true},
+		{name: "same host", origin: base, ok: true},
+		{name: "allowed", origin: "https://app.example.com", ok: true},
+		{name: "any origin is ignored", origin: "https://evil.com"},
+		{name: "invalid", origin: "://"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set(headerOrigin, tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http")+"/ws", header)
+			if tt.ok {
+				require.NoError(t, err)
+				_ = conn.Close()
+				return
+			}
+			require.Error(t, err)
+			require.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
+
+func TestWSClosedOnStop(t *testing.T) {
+	cfg := NewCfg()
+	served := make(chan struct{})
+	var h *Httpd
+	base := startHttpd(t, cfg, func(hd *Httpd) {
+		h = hd
+		h.GetServer(DefaultServerName).HandleWS("/ws", func(_ *http.Request, conn *WSConn) error {
+			close(served)
+			for {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					return err
+				}
+			}
+		})
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	<-served
+
+	runner.Stop(h)
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "err: %v", err)
+}
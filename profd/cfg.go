@@ -1,5 +1,11 @@
 package profd
 
+import (
+	"time"
+
+	"github.com/donkeywon/golib-daemon/httpd"
+)
+
 const (
 	DefaultEnableStartupProfiling = false
 	DefaultStartupProfilingSec    = 300
@@ -8,7 +14,9 @@ const (
 	DefaultEnableGoPs             = false
 	DefaultGoPsAddr               = ":"
 	DefaultEnableHTTPPprof        = false
+	DefaultHTTPPprofWriteTimeout  = 5 * time.Minute
 	DefaultEnableStatsViz         = false
+	DefaultHTTPServer             = httpd.DefaultServerName
 )
 
 type Cfg struct {
@@ -17,12 +25,15 @@ type Cfg struct {
 	StartupProfilingMode   string `yaml:"startupProfilingMode"     env:"PROF_STARTUP_PROFILING_MODE"     flag-long:"prof-startup-profiling-mode"   flag-description:"startup profiling mode, only works when prof-enable-startup-profiling is enabled"`
 	ProfilingOutputDir     string `yaml:"profilingOutputDir"       env:"PROF_OUTPUT_DIR"                 flag-long:"prof-output-dir"               flag-description:"dir path of pprof file save to"`
 
-	EnableHTTPPprof bool `yaml:"enableHTTPPprof" env:"PROF_ENABLE_HTTP_PPROF" flag-long:"prof-enable-http-pprof" flag-description:"enable pprof over http, need httpd"`
+	EnableHTTPPprof       bool          `yaml:"enableHTTPPprof"       env:"PROF_ENABLE_HTTP_PPROF"        flag-long:"prof-enable-http-pprof"        flag-description:"enable pprof over http, need httpd"`
+	HTTPPprofWriteTimeout time.Duration `yaml:"httpPprofWriteTimeout" env:"PROF_HTTP_PPROF_WRITE_TIMEOUT" flag-long:"prof-http-pprof-write-timeout" flag-description:"write timeout of pprof profile and trace over http, which must be longer than the seconds requested, overrides httpd write timeout"`
 
 	EnableGoPs bool   `yaml:"enableGoPs" env:"PROF_ENABLE_GOPS" flag-long:"prof-enable-gops" flag-description:"enable gops agent"`
 	GoPsAddr   string `yaml:"goPsAddr"   env:"PROF_GOPS_ADDR"   flag-long:"prof-gops-addr"   flag-description:"gops agent listen addr"`
 
 	EnableStatsViz bool `yaml:"enableStatsViz" env:"PROF_ENABLE_STATS_VIZ" flag-long:"prof-enable-stats-viz" flag-description:"enable statsviz, need httpd"`
+
+	HTTPServer string `yaml:"httpServer" env:"PROF_HTTP_SERVER" flag-long:"prof-http-server" flag-description:"name of the httpd server instance which serves pprof and statsviz, e.g. admin"`
 }
 
 func NewCfg() *Cfg {
@@ -34,6 +45,8 @@ func NewCfg() *Cfg {
 		EnableGoPs:             DefaultEnableGoPs,
 		GoPsAddr:               DefaultGoPsAddr,
 		EnableHTTPPprof:        DefaultEnableHTTPPprof,
+		HTTPPprofWriteTimeout:  DefaultHTTPPprofWriteTimeout,
 		EnableStatsViz:         DefaultEnableStatsViz,
+		HTTPServer:             DefaultHTTPServer,
 	}
 }
@@ -1,6 +1,7 @@
 package profd
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/pprof"
@@ -56,25 +57,31 @@ func (p *Profd) Init() error {
 		}
 	}
 
+	srv := httpd.GetServer(p.Cfg.HTTPServer)
+	rt := srv.Group("", srv.Auth())
+
 	if p.Cfg.EnableStatsViz {
-		srv, err := statsviz.NewServer()
+		sv, err := statsviz.NewServer()
 		if err != nil {
 			p.Error("init statsviz fail", err)
 		} else {
-			httpd.Handle("/debug/statsviz/", srv.Index())
-			httpd.HandleFunc("/debug/statsviz/ws", srv.Ws())
+			rt.Handle("/debug/statsviz/", sv.Index())
+			rt.HandleFunc("/debug/statsviz/ws", sv.Ws())
 		}
 	}
 
 	if p.Cfg.EnableHTTPPprof {
-		httpd.HandleFunc("/debug/pprof/", pprof.Index)
-		httpd.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-		httpd.HandleFunc("/debug/pprof/profile", pprof.Profile)
-		httpd.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-		httpd.HandleFunc("/debug/pprof/trace", pprof.Trace)
-
-		httpd.HandleRaw("/debug/pprof/start/{mode}", p.startProf)
-		httpd.HandleRaw("/debug/pprof/stop", p.stopProf)
+		rt.HandleFunc("/debug/pprof/", pprof.Index)
+		rt.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		rt.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+
+		// profile and trace last for seconds specified by client, default 30s
+		long := rt.WithRouteCfg(&httpd.RouteCfg{WriteTimeout: p.Cfg.HTTPPprofWriteTimeout})
+		long.HandleFunc("/debug/pprof/profile", routeWriteTimeout(pprof.Profile, 30))
+		long.HandleFunc("/debug/pprof/trace", routeWriteTimeout(pprof.Trace, 1))
+
+		rt.HandleRaw("/debug/pprof/start/{mode}", p.startProf)
+		rt.HandleRaw("/debug/pprof/stop", p.stopProf)
 	}
 
 	if p.Cfg.EnableGoPs {
@@ -135,3 +142,20 @@ func (p *Profd) stopProf(w http.ResponseWriter, r *http.Request) []byte {
 	}
 	return []byte("stopped")
 }
+
+// routeWriteTimeout makes pprof handler check the seconds requested against write timeout of the route instead of
+// the server, pprof refuses seconds exceeding WriteTimeout of http.Server in context.
+func routeWriteTimeout(handler http.HandlerFunc, defaultSec float64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sec, err := strconv.ParseFloat(r.FormValue("seconds"), 64)
+		if err != nil || sec <= 0 {
+			sec = defaultSec
+		}
+		_, writeTimeout := httpd.RouteTimeouts(r.Context())
+		if writeTimeout > 0 && sec >= writeTimeout.Seconds() {
+			http.Error(w, "profile duration exceeds write timeout", http.StatusBadRequest)
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, nil)))
+	}
+}
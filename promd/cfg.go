@@ -1,18 +1,27 @@
 package promd
 
+import "github.com/donkeywon/golib-daemon/httpd"
+
 const (
 	DefaultDisableGoCollector   = false
 	DefaultDisableProcCollector = false
+	DefaultDisableHTTPCollector = false
+	DefaultHTTPServer           = httpd.DefaultServerName
 )
 
 type Cfg struct {
 	DisableGoCollector   bool `env:"PROMETHEUS_DISABLE_GO_COLLECTOR"   flag-long:"prom-disable-go-collector"   yaml:"disableGoCollector" flag-description:"disable collect current go process runtime metrics"`
 	DisableProcCollector bool `env:"PROMETHEUS_DISABLE_PROC_COLLECTOR" flag-long:"prom-disable-proc-collector" yaml:"disableProcCollector" flag-description:"disable collect current state of process metrics including CPU, memory and file descriptor usage as well as the process start time"`
+	DisableHTTPCollector bool `env:"PROMETHEUS_DISABLE_HTTP_COLLECTOR" flag-long:"prom-disable-http-collector" yaml:"disableHTTPCollector" flag-description:"disable collect httpd request metrics including request count, latency, in-flight requests and request/response size"`
+
+	HTTPServer string `env:"PROMETHEUS_HTTP_SERVER" flag-long:"prom-http-server" yaml:"httpServer" flag-description:"name of the httpd server instance which serves /metrics, e.g. admin"`
 }
 
 func NewCfg() *Cfg {
 	return &Cfg{
 		DisableGoCollector:   DefaultDisableGoCollector,
 		DisableProcCollector: DefaultDisableProcCollector,
+		DisableHTTPCollector: DefaultDisableHTTPCollector,
+		HTTPServer:           DefaultHTTPServer,
 	}
 }
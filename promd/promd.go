@@ -42,6 +42,9 @@ func (p *Promd) Init() error {
 	if !p.DisableProcCollector {
 		p.reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 	}
+	if !p.DisableHTTPCollector {
+		p.reg.MustRegister(httpd.Collectors()...)
+	}
 
 	p.registerHTTPHandler()
 	return p.Runner.Init()
@@ -56,7 +59,8 @@ func (p *Promd) GetCfg() interface{} {
 }
 
 func (p *Promd) registerHTTPHandler() {
-	httpd.Handle("/metrics", promhttp.HandlerFor(p.reg, promhttp.HandlerOpts{Registry: p.reg}))
+	srv := httpd.GetServer(p.HTTPServer)
+	srv.Group("", srv.Auth()).Handle("/metrics", promhttp.HandlerFor(p.reg, promhttp.HandlerOpts{Registry: p.reg}))
 }
 
 func (p *Promd) SetGauge(name string, v float64) {
@@ -1,10 +1,12 @@
 package taskd
 
 import (
+	"context"
 	"errors"
 	"sync"
 
 	"github.com/alitto/pond"
+	"github.com/donkeywon/golib-daemon/httpd"
 	"github.com/donkeywon/golib/boot"
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/plugin"
@@ -18,6 +20,7 @@ const DaemonTypeTaskd boot.DaemonType = "taskd"
 var (
 	ErrStopping          = errors.New("stopping, reject")
 	ErrTaskAlreadyExists = errors.New("task already exists")
+	ErrQueueFull         = errors.New("task queue is full")
 )
 
 var _t = &Taskd{
@@ -41,6 +44,7 @@ func New() *Taskd {
 
 func (td *Taskd) Init() error {
 	td.pool = pond.New(td.Cfg.PoolSize, td.Cfg.QueueSize)
+	httpd.RegisterReadinessCheck("taskd_queue", td.queueCheck)
 	return td.Runner.Init()
 }
 
@@ -56,6 +60,18 @@ func (td *Taskd) Stop() error {
 	return nil
 }
 
+// queueCheck fails when task queue is full, new tasks will be blocked or rejected.
+// It never fails if queue is not buffered, submitting is blocked until a worker is idle in that case.
+func (td *Taskd) queueCheck(context.Context) error {
+	if td.Cfg.QueueSize <= 0 {
+		return nil
+	}
+	if waiting := td.pool.WaitingTasks(); waiting >= uint64(td.Cfg.QueueSize) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
 func (td *Taskd) Type() interface{} {
 	return DaemonTypeTaskd
 }
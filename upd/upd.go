@@ -1,12 +1,15 @@
 package upd
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/donkeywon/golib-daemon/httpd"
 	"github.com/donkeywon/golib/boot"
 	"github.com/donkeywon/golib/buildinfo"
 	"github.com/donkeywon/golib/errs"
@@ -19,6 +22,8 @@ import (
 
 const DaemonTypeUpd boot.DaemonType = "upd"
 
+var ErrUpgrading = errors.New("upgrading")
+
 var _u = &Upd{
 	Runner:             runner.Create(string(DaemonTypeUpd)),
 	upgradingBlockChan: make(chan struct{}),
@@ -36,6 +41,11 @@ func New() *Upd {
 	return _u
 }
 
+func (u *Upd) Init() error {
+	httpd.RegisterReadinessCheck("upd_upgrading", u.upgradingCheck)
+	return u.Runner.Init()
+}
+
 func (u *Upd) Stop() error {
 	u.Cancel()
 	if u.isUpgrading() {
@@ -64,6 +74,14 @@ func (u *Upd) isUpgrading() bool {
 	return u.upgrading.Load()
 }
 
+// upgradingCheck fails while upgrading, the process will be restarted soon.
+func (u *Upd) upgradingCheck(context.Context) error {
+	if u.isUpgrading() {
+		return ErrUpgrading
+	}
+	return nil
+}
+
 func (u *Upd) Upgrade(vi *VerInfo) {
 	go func() {
 		err := u.upgrade(vi)